@@ -0,0 +1,241 @@
+// Package blocks turns the node's block production into a stream of new block heights.
+//
+// A Source follows the node's block subscription when it offers one and falls back to
+// polling the block height otherwise. Polling adapts to the observed block interval:
+// it sleeps until the next block is due and then polls quickly until the block shows up.
+package blocks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dappley/iot-security/retry"
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultMinPollInterval = time.Second
+	defaultMaxPollInterval = time.Second * 10
+)
+
+// HeightFunc returns the current block height of the node.
+type HeightFunc func(ctx context.Context) (uint64, error)
+
+// SubscribeFunc subscribes to new blocks. The returned next blocks until the node
+// announces a block and fails once the subscription is broken.
+type SubscribeFunc func(ctx context.Context) (next func() error, err error)
+
+// Config is the JSON form of the polling settings. Zero values fall back to the defaults.
+type Config struct {
+	MinPollIntervalMs int64
+	MaxPollIntervalMs int64
+	// DisableStream always polls, even if the node offers a block subscription
+	DisableStream bool
+}
+
+type Source struct {
+	height    HeightFunc
+	subscribe SubscribeFunc
+	poller    poller
+	retry     retry.Policy
+	heights   chan uint64
+	errors    chan error
+	last      uint64
+}
+
+// NewSource creates a source that polls height, or follows subscribe if it is not nil.
+func NewSource(height HeightFunc, subscribe SubscribeFunc, config Config, retryPolicy retry.Policy) *Source {
+	p := poller{min: defaultMinPollInterval, max: defaultMaxPollInterval}
+	if config.MinPollIntervalMs > 0 {
+		p.min = time.Duration(config.MinPollIntervalMs) * time.Millisecond
+	}
+	if config.MaxPollIntervalMs > 0 {
+		p.max = time.Duration(config.MaxPollIntervalMs) * time.Millisecond
+	}
+	if p.max < p.min {
+		p.max = p.min
+	}
+	if config.DisableStream {
+		subscribe = nil
+	}
+	return &Source{
+		height:    height,
+		subscribe: subscribe,
+		poller:    p,
+		retry:     retryPolicy,
+		heights:   make(chan uint64, 1),
+		errors:    make(chan error, 1),
+	}
+}
+
+// Heights delivers every new block height once. If the reader falls behind, it gets
+// the latest height instead of a backlog of stale ones.
+func (s *Source) Heights() <-chan uint64 { return s.heights }
+
+// Errors delivers the failures of the source. The source retries them by itself.
+func (s *Source) Errors() <-chan error { return s.errors }
+
+// Run produces heights until ctx is cancelled.
+func (s *Source) Run(ctx context.Context) {
+	failures := 0
+	for ctx.Err() == nil {
+		if s.subscribe == nil {
+			s.poll(ctx)
+			return
+		}
+		subscribed, err := s.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if statusCode(err) == codes.Unimplemented {
+			logger.Info("The node does not offer a block subscription. Polling the block height instead.")
+			s.subscribe = nil
+			continue
+		}
+		//a subscription that worked for a while starts the backoff anew
+		if subscribed {
+			failures = 0
+		}
+		failures++
+		s.report(err)
+		if !sleep(ctx, s.retry.Backoff(failures)) {
+			return
+		}
+	}
+}
+
+// statusCode returns the gRPC code of err, which the callers usually wrap, e.g. with errs.RPC
+func statusCode(err error) codes.Code {
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		return se.GRPCStatus().Code()
+	}
+	return status.Code(err)
+}
+
+// follow emits a height for every block announced by the subscription until it breaks.
+// It reports whether the subscription was established.
+func (s *Source) follow(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	next, err := s.subscribe(ctx)
+	if err != nil {
+		return false, err
+	}
+	//catch up with the blocks produced while there was no subscription
+	if err := s.update(ctx); err != nil {
+		return true, err
+	}
+	for {
+		if err := next(); err != nil {
+			return true, err
+		}
+		if err := s.update(ctx); err != nil {
+			return true, err
+		}
+	}
+}
+
+func (s *Source) poll(ctx context.Context) {
+	failures := 0
+	for {
+		delay := time.Duration(0)
+		last := s.last
+		if err := s.update(ctx); err != nil {
+			failures++
+			s.report(err)
+			delay = s.retry.Backoff(failures)
+		} else {
+			failures = 0
+			delay = s.poller.observe(s.last-last, time.Now())
+		}
+		if !sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+func (s *Source) update(ctx context.Context) error {
+	height, err := s.height(ctx)
+	if err != nil {
+		return err
+	}
+	s.emit(height)
+	return nil
+}
+
+func (s *Source) emit(height uint64) {
+	if height <= s.last {
+		return
+	}
+	s.last = height
+	//replace a height the reader has not picked up yet
+	select {
+	case <-s.heights:
+	default:
+	}
+	s.heights <- height
+}
+
+func (s *Source) report(err error) {
+	select {
+	case s.errors <- err:
+	default:
+		logger.Warn("Block source failed. Error:", err)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// poller estimates the block interval from the heights it sees and picks the next poll delay
+type poller struct {
+	min, max   time.Duration
+	blockTime  time.Duration
+	lastChange time.Time
+	misses     int
+}
+
+// observe records that the height grew by blocks at now and returns the delay before the next poll
+func (p *poller) observe(blocks uint64, now time.Time) time.Duration {
+	if blocks == 0 {
+		//the next block is due, poll quickly and back off while it does not show up
+		p.misses++
+		delay := p.min << uint(p.misses-1)
+		if delay > p.max || delay <= 0 {
+			delay = p.max
+		}
+		return delay
+	}
+
+	if !p.lastChange.IsZero() {
+		sample := now.Sub(p.lastChange) / time.Duration(blocks)
+		if p.blockTime == 0 {
+			p.blockTime = sample
+		} else {
+			p.blockTime = (3*p.blockTime + sample) / 4
+		}
+	}
+	p.lastChange = now
+	p.misses = 0
+
+	delay := p.blockTime
+	if delay < p.min {
+		delay = p.min
+	}
+	if delay > p.max {
+		delay = p.max
+	}
+	return delay
+}
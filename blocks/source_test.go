@@ -0,0 +1,186 @@
+package blocks
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/retry"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testConfig = Config{MinPollIntervalMs: 1, MaxPollIntervalMs: 5}
+
+var testPolicy = retry.Policy{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1}
+
+func counter(height *uint64) HeightFunc {
+	return func(ctx context.Context) (uint64, error) {
+		return atomic.LoadUint64(height), nil
+	}
+}
+
+func receive(t *testing.T, s *Source) uint64 {
+	select {
+	case height := <-s.Heights():
+		return height
+	case <-time.After(time.Second * 5):
+		t.Fatal("no block height is received")
+		return 0
+	}
+}
+
+func TestSource_Poll(t *testing.T) {
+	height := uint64(7)
+	s := NewSource(counter(&height), nil, testConfig, testPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	assert.Equal(t, uint64(7), receive(t, s))
+	atomic.StoreUint64(&height, 8)
+	assert.Equal(t, uint64(8), receive(t, s))
+
+	//a height is only delivered once
+	select {
+	case h := <-s.Heights():
+		t.Fatal("unexpected height", h)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestSource_Stream(t *testing.T) {
+	height := uint64(3)
+	announce := make(chan struct{})
+	subscribe := func(ctx context.Context) (func() error, error) {
+		return func() error {
+			select {
+			case <-announce:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, nil
+	}
+	s := NewSource(counter(&height), subscribe, testConfig, testPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	assert.Equal(t, uint64(3), receive(t, s))
+	atomic.StoreUint64(&height, 4)
+	announce <- struct{}{}
+	assert.Equal(t, uint64(4), receive(t, s))
+}
+
+func TestSource_FallsBackToPolling(t *testing.T) {
+	height := uint64(5)
+	subscribe := func(ctx context.Context) (func() error, error) {
+		return func() error {
+			return status.Error(codes.Unimplemented, "unknown method RpcSubscribe")
+		}, nil
+	}
+	s := NewSource(counter(&height), subscribe, testConfig, testPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	assert.Equal(t, uint64(5), receive(t, s))
+	atomic.StoreUint64(&height, 6)
+	assert.Equal(t, uint64(6), receive(t, s))
+}
+
+//the monitor wraps the errors of the node, see errs.RPC
+func TestSource_FallsBackToPollingOnWrappedError(t *testing.T) {
+	height := uint64(5)
+	subscribe := func(ctx context.Context) (func() error, error) {
+		return nil, errs.RPC("subscribe to blocks", status.Error(codes.Unimplemented, "unknown method RpcSubscribe"))
+	}
+	s := NewSource(counter(&height), subscribe, testConfig, testPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	assert.Equal(t, uint64(5), receive(t, s))
+	atomic.StoreUint64(&height, 6)
+	assert.Equal(t, uint64(6), receive(t, s))
+	select {
+	case err := <-s.Errors():
+		t.Fatal("the missing subscription is reported as a failure:", err)
+	default:
+	}
+}
+
+func TestSource_ResetsBackoffAfterSubscribing(t *testing.T) {
+	height := uint64(5)
+	var subscriptions int32
+	subscribe := func(ctx context.Context) (func() error, error) {
+		atomic.AddInt32(&subscriptions, 1)
+		return func() error {
+			return errs.RPC("receive block", status.Error(codes.Unavailable, "connection reset"))
+		}, nil
+	}
+	//without the reset, the third subscription would wait for an hour
+	policy := retry.Policy{InitialInterval: time.Millisecond, MaxInterval: time.Hour, Multiplier: 1000000}
+	s := NewSource(counter(&height), subscribe, testConfig, policy)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	deadline := time.Now().Add(time.Second * 5)
+	for atomic.LoadInt32(&subscriptions) < 3 && time.Now().Before(deadline) {
+		select {
+		case <-s.Errors():
+		case <-s.Heights():
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+	assert.True(t, atomic.LoadInt32(&subscriptions) >= 3)
+}
+
+func TestSource_ReportsErrors(t *testing.T) {
+	failed := errors.New("node is down")
+	s := NewSource(func(ctx context.Context) (uint64, error) { return 0, failed }, nil, testConfig, testPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	select {
+	case err := <-s.Errors():
+		assert.Equal(t, failed, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("no error is reported")
+	}
+}
+
+func TestSource_DeliversLatestHeight(t *testing.T) {
+	s := NewSource(nil, nil, testConfig, testPolicy)
+	s.emit(1)
+	s.emit(2)
+	s.emit(2)
+	assert.Equal(t, uint64(2), <-s.Heights())
+	assert.Equal(t, 0, len(s.Heights()))
+}
+
+func TestPoller_Observe(t *testing.T) {
+	p := poller{min: time.Second, max: time.Second * 30}
+	start := time.Now()
+
+	assert.Equal(t, time.Second, p.observe(100, start))
+	//blocks every 15 seconds: sleep until the next one is due
+	assert.Equal(t, time.Second*15, p.observe(1, start.Add(time.Second*15)))
+	assert.Equal(t, time.Second*15, p.observe(2, start.Add(time.Second*45)))
+
+	//then poll quickly and back off while the block is late
+	assert.Equal(t, time.Second, p.observe(0, start.Add(time.Second*60)))
+	assert.Equal(t, time.Second*2, p.observe(0, start.Add(time.Second*61)))
+	assert.Equal(t, time.Second*4, p.observe(0, start.Add(time.Second*63)))
+	for i := 0; i < 70; i++ {
+		p.observe(0, start)
+	}
+	assert.Equal(t, time.Second*30, p.observe(0, start))
+}
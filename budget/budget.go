@@ -0,0 +1,185 @@
+// Package budget keeps the coins a device spends on registrations within a configured budget.
+//
+// The spending of the current period, a number of hours or an epoch of blocks, is kept in a
+// file so that a restart does not reset it. When the wallet balance falls below the low
+// balance mark, by default the cost of 100 registrations, registrations are thinned out to
+// every DegradedInterval-th target cycle and an alert is logged on every decision until the
+// wallet is refilled.
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAmount           = 1
+	defaultPeriodHours      = 24
+	defaultDegradedInterval = 4
+	//the default low balance mark, in registrations
+	defaultLowBalanceFees = 100
+)
+
+// Fee is what a single send costs the sender.
+type Fee struct {
+	Amount uint64
+	Tip    uint64
+}
+
+// NewFee returns the fee of a send. The amount defaults to 1 coin.
+func NewFee(amount uint64, tip uint64) Fee {
+	if amount == 0 {
+		amount = defaultAmount
+	}
+	return Fee{Amount: amount, Tip: tip}
+}
+
+func (f Fee) Cost() uint64 {
+	return f.Amount + f.Tip
+}
+
+// Config is the JSON form of the budget. Zero values disable a limit or fall back to the defaults.
+type Config struct {
+	// Limit is the most coins spent per period
+	Limit uint64
+	// PeriodHours is the length of a period, 24 by default
+	PeriodHours uint64
+	// PeriodBlocks makes a period an epoch of blocks instead of hours
+	PeriodBlocks uint64
+	// LowBalance is the wallet balance below which registrations are thinned out, the cost of
+	// 100 registrations by default
+	LowBalance uint64
+	// DegradedInterval is the number of target cycles per registration while the balance is low
+	DegradedInterval uint64
+}
+
+type state struct {
+	Period uint64 `json:"period"`
+	Spent  uint64 `json:"spent"`
+}
+
+type Tracker struct {
+	config Config
+	path   string
+	state  state
+	cycles uint64
+	//low is set while the balance is below the low balance mark
+	low bool
+}
+
+// Open loads the spending kept at path.
+func Open(path string, config Config) (*Tracker, error) {
+	if config.PeriodHours == 0 {
+		config.PeriodHours = defaultPeriodHours
+	}
+	if config.DegradedInterval == 0 {
+		config.DegradedInterval = defaultDegradedInterval
+	}
+	t := &Tracker{config: config, path: path}
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &t.state); err != nil {
+		return nil, fmt.Errorf("invalid budget file %s: %v", path, err)
+	}
+	return t, nil
+}
+
+// Allow decides whether to register in a new target cycle. It returns the reason if not.
+func (t *Tracker) Allow(cost uint64, balance uint64, blkHeight uint64, now time.Time) (bool, string) {
+	t.cycles++
+	metrics.GetOrRegisterGauge("wallet.balance", nil).Update(int64(balance))
+
+	if balance < cost {
+		t.alert(balance, t.lowBalance(cost), "the wallet can not pay for a registration")
+		return false, "the wallet balance is too low"
+	}
+	if t.config.Limit > 0 && t.spent(blkHeight, now)+cost > t.config.Limit {
+		return false, "the budget of the period is used up"
+	}
+	lowBalance := t.lowBalance(cost)
+	if balance < lowBalance {
+		if !t.low {
+			t.low = true
+			logger.WithFields(logger.Fields{
+				"balance":     balance,
+				"low_balance": lowBalance,
+			}).Warn("The wallet balance has fallen below the low balance mark")
+		}
+		t.alert(balance, lowBalance, fmt.Sprintf("the wallet is running low, registering every %d target cycles", t.config.DegradedInterval))
+		if t.cycles%t.config.DegradedInterval != 0 {
+			return false, "the wallet balance is low"
+		}
+		return true, ""
+	}
+	if t.low {
+		t.low = false
+		logger.WithFields(logger.Fields{
+			"balance":     balance,
+			"low_balance": lowBalance,
+		}).Info("The wallet balance is back above the low balance mark")
+	}
+	return true, ""
+}
+
+// lowBalance returns the low balance mark for registrations of cost coins
+func (t *Tracker) lowBalance(cost uint64) uint64 {
+	if t.config.LowBalance > 0 {
+		return t.config.LowBalance
+	}
+	return cost * defaultLowBalanceFees
+}
+
+// Spend records a send of cost coins.
+func (t *Tracker) Spend(cost uint64, blkHeight uint64, now time.Time) error {
+	t.state.Spent = t.spent(blkHeight, now) + cost
+	metrics.GetOrRegisterGauge("budget.spent", nil).Update(int64(t.state.Spent))
+	return t.save()
+}
+
+// spent returns the coins spent in the period of blkHeight and now, starting a new period if needed
+func (t *Tracker) spent(blkHeight uint64, now time.Time) uint64 {
+	period := uint64(now.Unix()) / (t.config.PeriodHours * 3600)
+	if t.config.PeriodBlocks > 0 {
+		period = blkHeight / t.config.PeriodBlocks
+	}
+	if period != t.state.Period {
+		t.state = state{Period: period}
+	}
+	return t.state.Spent
+}
+
+func (t *Tracker) alert(balance uint64, lowBalance uint64, message string) {
+	metrics.GetOrRegisterCounter("wallet.alerts", nil).Inc(1)
+	logger.WithFields(logger.Fields{
+		"alert":       "wallet",
+		"balance":     balance,
+		"low_balance": lowBalance,
+	}).Error("Wallet alert: ", message)
+}
+
+func (t *Tracker) save() error {
+	raw, err := json.Marshal(t.state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
@@ -0,0 +1,96 @@
+package budget
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempBudget(t *testing.T, config Config) (*Tracker, string, func()) {
+	dir, err := ioutil.TempDir("", "budget")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "budget.json")
+	tracker, err := Open(path, config)
+	assert.Nil(t, err)
+	return tracker, path, func() { os.RemoveAll(dir) }
+}
+
+func TestNewFee(t *testing.T) {
+	assert.Equal(t, Fee{Amount: 1}, NewFee(0, 0))
+	assert.Equal(t, uint64(5), NewFee(2, 3).Cost())
+}
+
+func TestTracker_Limit(t *testing.T) {
+	tracker, path, cleanup := tempBudget(t, Config{Limit: 3, PeriodBlocks: 100})
+	defer cleanup()
+	now := time.Now()
+
+	for height := uint64(10); height < 13; height++ {
+		allowed, _ := tracker.Allow(1, 1000, height, now)
+		assert.True(t, allowed)
+		assert.Nil(t, tracker.Spend(1, height, now))
+	}
+	allowed, reason := tracker.Allow(1, 1000, 13, now)
+	assert.False(t, allowed)
+	assert.Equal(t, "the budget of the period is used up", reason)
+
+	//the spending survives a restart
+	tracker, err := Open(path, Config{Limit: 3, PeriodBlocks: 100})
+	assert.Nil(t, err)
+	allowed, _ = tracker.Allow(1, 1000, 14, now)
+	assert.False(t, allowed)
+
+	//and starts over in the next epoch
+	allowed, _ = tracker.Allow(1, 1000, 100, now)
+	assert.True(t, allowed)
+}
+
+func TestTracker_PeriodHours(t *testing.T) {
+	tracker, _, cleanup := tempBudget(t, Config{Limit: 1})
+	defer cleanup()
+	day := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, tracker.Spend(1, 1, day))
+	allowed, _ := tracker.Allow(1, 1000, 2, day.Add(time.Hour))
+	assert.False(t, allowed)
+	allowed, _ = tracker.Allow(1, 1000, 3, day.Add(time.Hour*24))
+	assert.True(t, allowed)
+}
+
+func TestTracker_LowBalance(t *testing.T) {
+	tracker, _, cleanup := tempBudget(t, Config{LowBalance: 100, DegradedInterval: 3})
+	defer cleanup()
+	now := time.Now()
+
+	allowed, _ := tracker.Allow(1, 1000, 1, now)
+	assert.True(t, allowed)
+
+	//only every third target cycle is registered while the balance is low
+	var decisions []bool
+	for i := 0; i < 6; i++ {
+		allowed, _ := tracker.Allow(1, 50, 1, now)
+		decisions = append(decisions, allowed)
+	}
+	assert.Equal(t, []bool{false, true, false, false, true, false}, decisions)
+
+	allowed, reason := tracker.Allow(2, 1, 1, now)
+	assert.False(t, allowed)
+	assert.Equal(t, "the wallet balance is too low", reason)
+}
+
+func TestTracker_DefaultLowBalance(t *testing.T) {
+	tracker, _, cleanup := tempBudget(t, Config{DegradedInterval: 3})
+	defer cleanup()
+	now := time.Now()
+
+	//the mark defaults to the cost of 100 registrations
+	allowed, _ := tracker.Allow(2, 200, 1, now)
+	assert.True(t, allowed)
+	allowed, reason := tracker.Allow(2, 199, 1, now)
+	assert.False(t, allowed)
+	assert.Equal(t, "the wallet balance is low", reason)
+}
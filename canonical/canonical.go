@@ -0,0 +1,202 @@
+// Package canonical encodes JSON in the canonical form of RFC 8785 (JCS), so that
+// the bytes signed by a device are the bytes the contract rebuilds when it verifies them.
+//
+// Object members are sorted by the UTF-16 code units of their names, there is no
+// whitespace, strings only escape what JSON requires and numbers are written the
+// way ECMAScript's JSON.stringify writes them. The contract implements the same
+// encoding in canonicalize, and testdata/vectors.json holds the vectors both sides are tested against.
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Marshal returns the canonical JSON encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Transform(raw)
+}
+
+// Transform rewrites a JSON document into its canonical form.
+func Transform(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := encodeValue(dec, &buf); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("canonical: unexpected data after the top-level value")
+	}
+	return buf.Bytes(), nil
+}
+
+type member struct {
+	name  string
+	value []byte
+}
+
+func encodeValue(dec *json.Decoder, buf *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			return encodeObject(dec, buf)
+		}
+		return encodeArray(dec, buf)
+	case string:
+		writeString(buf, t)
+	case json.Number:
+		s, err := formatNumber(t)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func encodeObject(dec *json.Decoder, buf *bytes.Buffer) error {
+	var members []member
+	seen := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		if seen[name] {
+			return fmt.Errorf("canonical: duplicate member %q", name)
+		}
+		seen[name] = true
+		var value bytes.Buffer
+		if err := encodeValue(dec, &value); err != nil {
+			return err
+		}
+		members = append(members, member{name, value.Bytes()})
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return lessUTF16(members[i].name, members[j].name)
+	})
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeString(buf, m.name)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func encodeArray(dec *json.Decoder, buf *bytes.Buffer) error {
+	buf.WriteByte('[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encodeValue(dec, buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	_, err := dec.Token()
+	return err
+}
+
+// lessUTF16 orders names by UTF-16 code units like Array.prototype.sort in the contract.
+func lessUTF16(a, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formatNumber writes n as an IEEE 754 double in the ECMAScript format. Integers that
+// a double can not hold exactly are rejected instead of being silently rounded.
+func formatNumber(n json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("canonical: number %s is out of range", n)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+	if format == 'e' {
+		//ECMAScript writes 1e-7 where Go writes 1e-07
+		if l := len(s); l >= 4 && s[l-4] == 'e' && s[l-3] == '-' && s[l-2] == '0' {
+			s = s[:l-2] + s[l-1:]
+		}
+	}
+	if !strings.ContainsAny(string(n), ".eE") {
+		exact, _ := new(big.Int).SetString(string(n), 10)
+		rounded, _ := big.NewFloat(f).Int(nil)
+		if exact.Cmp(rounded) != 0 {
+			return "", fmt.Errorf("canonical: integer %s can not be represented exactly", n)
+		}
+	}
+	return s, nil
+}
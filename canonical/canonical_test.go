@@ -0,0 +1,85 @@
+package canonical
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dappley/go-dappley/crypto/keystore/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+// vectors.json is shared with contract_test.go so that both encodings are held to the same output
+type vectors struct {
+	Canonical []struct {
+		Name      string          `json:"name"`
+		Input     json.RawMessage `json:"input"`
+		Canonical string          `json:"canonical"`
+	} `json:"canonical"`
+	Signatures []struct {
+		Name      string          `json:"name"`
+		Info      json.RawMessage `json:"info"`
+		Canonical string          `json:"canonical"`
+		Addr      string          `json:"addr"`
+		PubKey    string          `json:"pubKey"`
+		Sig       string          `json:"sig"`
+	} `json:"signatures"`
+}
+
+func loadVectors(t *testing.T) vectors {
+	raw, err := ioutil.ReadFile("testdata/vectors.json")
+	assert.Nil(t, err)
+	var v vectors
+	assert.Nil(t, json.Unmarshal(raw, &v))
+	return v
+}
+
+func TestTransform_Vectors(t *testing.T) {
+	for _, v := range loadVectors(t).Canonical {
+		out, err := Transform(v.Input)
+		assert.Nil(t, err, v.Name)
+		assert.Equal(t, v.Canonical, string(out), v.Name)
+	}
+}
+
+func TestSignatureVectors(t *testing.T) {
+	for _, v := range loadVectors(t).Signatures {
+		out, err := Transform(v.Info)
+		assert.Nil(t, err, v.Name)
+		assert.Equal(t, v.Canonical, string(out), v.Name)
+
+		digest := sha256.Sum256(out)
+		sig, err := hex.DecodeString(v.Sig)
+		assert.Nil(t, err)
+		pubKey, err := secp256k1.RecoverPubkey(digest[:], sig)
+		assert.Nil(t, err, v.Name)
+		assert.Equal(t, v.PubKey, hex.EncodeToString(pubKey[1:]), v.Name)
+	}
+}
+
+func TestMarshal_Info(t *testing.T) {
+	info := struct {
+		Data      string
+		BlkHeight uint64 `json:",string"`
+	}{"kernel=<4.19>&rt", 18446744073709551615}
+
+	out, err := Marshal(info)
+	assert.Nil(t, err)
+	//unlike json.Marshal, the canonical form does not escape HTML characters
+	assert.Equal(t, `{"BlkHeight":"18446744073709551615","Data":"kernel=<4.19>&rt"}`, string(out))
+}
+
+func TestTransform_Rejects(t *testing.T) {
+	for _, input := range []string{
+		`{"a":1,"a":2}`,
+		`9007199254740993`,
+		`1e400`,
+		`{"a":1} {}`,
+		`{"a":`,
+	} {
+		_, err := Transform([]byte(input))
+		assert.NotNil(t, err, input)
+	}
+}
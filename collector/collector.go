@@ -0,0 +1,104 @@
+// Package collector defines the pluggable measurement sources of the monitor.
+//
+// A Collector produces one named, canonical measurement of the device. The
+// monitor enables collectors by name from its config and combines their
+// measurements into the data it signs and registers with the contract.
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dappley/iot-security/measure"
+)
+
+const DefaultCollector = "filetree"
+
+type Measurement struct {
+	Name  string
+	Value string
+}
+
+type Collector interface {
+	Name() string
+	Collect() (Measurement, error)
+}
+
+// Settings carries the parts of the monitor config that collectors may need
+type Settings struct {
+	Paths    []measure.Policy
+	StateDir string
+}
+
+type Factory func(settings Settings) (Collector, error)
+
+var registry = map[string]Factory{}
+
+// Register makes a collector available by name. It panics if the name is registered twice.
+func Register(name string, factory Factory) {
+	if _, ok := registry[name]; ok {
+		panic("collector: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+func Names() []string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the named collectors. The default collector is used if no name is given.
+func New(names []string, settings Settings) ([]Collector, error) {
+	if len(names) == 0 {
+		names = []string{DefaultCollector}
+	}
+	var collectors []Collector
+	seen := make(map[string]bool)
+	for _, name := range names {
+		factory, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q (available: %s)", name, strings.Join(Names(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("collector %q is enabled twice", name)
+		}
+		seen[name] = true
+		c, err := factory(settings)
+		if err != nil {
+			return nil, fmt.Errorf("collector %q: %v", name, err)
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors, nil
+}
+
+// CollectAll runs every collector and combines the results.
+func CollectAll(collectors []Collector) (string, error) {
+	var measurements []Measurement
+	for _, c := range collectors {
+		m, err := c.Collect()
+		if err != nil {
+			return "", fmt.Errorf("collector %q: %v", c.Name(), err)
+		}
+		measurements = append(measurements, m)
+	}
+	return Combine(measurements), nil
+}
+
+// Combine encodes measurements as "name=value" pairs sorted by name and joined by ";",
+// so the result does not depend on the order collectors were enabled in.
+func Combine(measurements []Measurement) string {
+	sorted := append([]Measurement(nil), measurements...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	parts := make([]string, len(sorted))
+	for i, m := range sorted {
+		parts[i] = m.Name + "=" + m.Value
+	}
+	return strings.Join(parts, ";")
+}
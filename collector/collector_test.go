@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dappley/iot-security/measure"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticCollector struct {
+	name  string
+	value string
+}
+
+func (c staticCollector) Name() string { return c.name }
+
+func (c staticCollector) Collect() (Measurement, error) {
+	return Measurement{Name: c.name, Value: c.value}, nil
+}
+
+func TestCombine_OrderIndependent(t *testing.T) {
+	a := Measurement{Name: "filetree", Value: "abc"}
+	b := Measurement{Name: "kernel", Value: "4.19"}
+
+	assert.Equal(t, "filetree=abc;kernel=4.19", Combine([]Measurement{a, b}))
+	assert.Equal(t, "filetree=abc;kernel=4.19", Combine([]Measurement{b, a}))
+}
+
+func TestNew(t *testing.T) {
+	Register("static", func(settings Settings) (Collector, error) {
+		return staticCollector{"static", "v1"}, nil
+	})
+	defer delete(registry, "static")
+
+	root, err := ioutil.TempDir("", "collector")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	settings := Settings{
+		Paths:    []measure.Policy{{Path: root, Exclude: []string{"state"}}},
+		StateDir: filepath.Join(root, "state"),
+	}
+
+	_, err = New([]string{"unknown"}, settings)
+	assert.NotNil(t, err)
+	_, err = New([]string{"static", "static"}, settings)
+	assert.NotNil(t, err)
+
+	collectors, err := New(nil, settings)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(collectors))
+	assert.Equal(t, DefaultCollector, collectors[0].Name())
+
+	collectors, err = New([]string{"static", "filetree"}, settings)
+	assert.Nil(t, err)
+	data, err := CollectAll(collectors)
+	assert.Nil(t, err)
+	assert.Regexp(t, "^filetree=[0-9a-f]{64};static=v1$", data)
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/dappley/iot-security/measure"
+	logger "github.com/sirupsen/logrus"
+)
+
+func init() {
+	Register("filetree", NewFileTreeCollector)
+}
+
+// FileTreeCollector measures one Merkle root over all monitored paths
+type FileTreeCollector struct {
+	policies []measure.Policy
+	leafFile string
+}
+
+func NewFileTreeCollector(settings Settings) (Collector, error) {
+	if len(settings.Paths) == 0 {
+		return nil, errors.New("no monitored path is set")
+	}
+	for _, policy := range settings.Paths {
+		if err := policy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &FileTreeCollector{
+		policies: settings.Paths,
+		leafFile: filepath.Join(settings.StateDir, "leaves.json"),
+	}, nil
+}
+
+func (c *FileTreeCollector) Name() string { return "filetree" }
+
+func (c *FileTreeCollector) Collect() (Measurement, error) {
+	//the previous leaf list is the baseline of append-only paths
+	prev, err := measure.Load(c.leafFile)
+	if err != nil {
+		prev = nil
+	}
+	measurement, err := measure.MeasurePolicies(c.policies, prev)
+	if err != nil {
+		return Measurement{}, err
+	}
+	c.record(prev, measurement)
+	return Measurement{Name: c.Name(), Value: measurement.Root}, nil
+}
+
+// record keeps the per-file leaf list locally and explains what changed since the last measurement
+func (c *FileTreeCollector) record(prev *measure.Measurement, measurement *measure.Measurement) {
+	if prev != nil && prev.Root != measurement.Root {
+		logger.WithFields(logger.Fields{
+			"prev_root": prev.Root,
+			"curr_root": measurement.Root,
+		}).Warn("Monitored directory has changed!")
+		for _, change := range measure.Diff(prev, measurement) {
+			logger.WithFields(logger.Fields{
+				"path":   change.Path,
+				"change": change.Kind,
+				"fields": change.Fields,
+			}).Warn("Monitored file has changed")
+		}
+	}
+
+	if err := measurement.Save(c.leafFile); err != nil {
+		logger.Warn("Unable to save measurement leaves. Error:", err)
+	}
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/signer"
+)
+
+// Kinds of config files
+const (
+	KindMonitor = "monitor"
+	KindCommon  = "common"
+	KindAdmin   = "admin"
+)
+
+// Report holds the problems found in one config file by Check
+type Report struct {
+	File   string
+	Kind   string
+	Errors ErrorList
+}
+
+func (r Report) OK() bool { return len(r.Errors) == 0 }
+
+func (r Report) String() string {
+	if r.OK() {
+		return fmt.Sprintf("%s: ok (%s config)", r.File, r.Kind)
+	}
+	return fmt.Sprintf("%s: %d problem(s) (%s config)", r.File, len(r.Errors), r.Kind)
+}
+
+// Check loads and validates every config file in paths. Directories are searched for
+// .conf, .json, .yaml, .yml and .toml files.
+// On top of validating each file, it checks that
+//   - the private keys derive the public keys and the public keys hash to the addresses in the same file
+//   - the sender addresses are in the wallet, if inWallet is not nil
+//   - no two monitors connect to the same local RPC port
+//
+// Private keys in a keystore are not checked, since that would need their passphrase.
+func Check(paths []string, inWallet func(addr string) bool) ([]Report, error) {
+	files, err := expand(paths)
+	if err != nil {
+		return nil, err
+	}
+	var checked []*checkedFile
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errs.Config("read config", err)
+		}
+		f := &checkedFile{kind: detectKind(data, FormatOf(file))}
+		var config validator
+		switch f.kind {
+		case KindCommon:
+			config = &Common{}
+		case KindAdmin:
+			config = &Admin{}
+		default:
+			config = &Monitor{}
+		}
+		if f.checker, err = parse(file, config, nil); err != nil {
+			return nil, err
+		}
+		if len(f.checker.errors) == 0 {
+			switch config := config.(type) {
+			case *Monitor:
+				config.checkKeys(f.checker, inWallet)
+				f.monitor = config
+			case *Admin:
+				config.checkKeys(f.checker, inWallet)
+			}
+		}
+		checked = append(checked, f)
+	}
+	checkPorts(checked)
+
+	reports := make([]Report, len(checked))
+	for i, f := range checked {
+		reports[i] = Report{File: f.checker.file, Kind: f.kind, Errors: f.checker.errors}
+	}
+	return reports, nil
+}
+
+type checkedFile struct {
+	kind    string
+	checker *checker
+	// monitor is set if the file is a valid monitor config
+	monitor *Monitor
+}
+
+// filePatterns match the config files in a directory
+var filePatterns = []string{"*.conf", "*.json", "*.yaml", "*.yml", "*.toml"}
+
+// expand replaces the directories in paths with the config files they hold
+func expand(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errs.Config("read config", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		var matches []string
+		for _, pattern := range filePatterns {
+			found, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, found...)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// detectKind tells the kind of a config file from its keys. Files that can not be decoded are taken as monitor configs.
+func detectKind(data []byte, format string) string {
+	doc, err := decodeDocument(data, format)
+	if err != nil || len(doc) == 0 {
+		return KindMonitor
+	}
+	common := true
+	for _, key := range doc.keys() {
+		switch strings.ToLower(key) {
+		case "adminpubkey", "adminprivkey", "adminkeystore", "addresses":
+			return KindAdmin
+		case "contractaddr", "contractsha256":
+		default:
+			common = false
+		}
+	}
+	if common {
+		return KindCommon
+	}
+	return KindMonitor
+}
+
+// checkKey checks that privKey derives pubKey and that the key hashes to addr. Unset values are not checked.
+func (c *checker) checkKey(privKeyField, privKey, pubKeyField, pubKey, addrField, addr string) {
+	if privKey != "" {
+		raw, _ := hex.DecodeString(privKey)
+		s, err := signer.NewSoftwareSigner(raw)
+		if err != nil {
+			c.add(privKeyField, "", "%v", err)
+			return
+		}
+		derived := hex.EncodeToString(s.PublicKey())
+		if pubKey != "" && !strings.EqualFold(pubKey, derived) {
+			c.add(pubKeyField, "", "does not match %s, which derives the public key %s", privKeyField, derived)
+		}
+		if addrField != "" && addr != "" && addr != s.Address() {
+			c.add(addrField, addr, "%s does not match %s, which belongs to the address %s", addr, privKeyField, s.Address())
+		}
+		return
+	}
+	if pubKey == "" || addrField == "" || addr == "" {
+		return
+	}
+	raw, _ := hex.DecodeString(pubKey)
+	derived, err := signer.AddressOf(raw)
+	if err != nil {
+		c.add(pubKeyField, "", "%v", err)
+		return
+	}
+	if addr != derived {
+		c.add(addrField, addr, "%s does not match %s, which hashes to %s", addr, pubKeyField, derived)
+	}
+}
+
+func (c *checker) inWallet(field string, addr string, inWallet func(addr string) bool) {
+	if inWallet != nil && !inWallet(addr) {
+		c.add(field, addr, "%s is not in the wallet", addr)
+	}
+}
+
+func (m *Monitor) checkKeys(c *checker, inWallet func(addr string) bool) {
+	c.checkKey("nodePrivateKey", m.NodePrivateKey, "nodePubKey", m.NodePubkey, "nodeAddr", m.NodeAddr)
+	c.inWallet("senderAddr", m.SenderAddr, inWallet)
+}
+
+func (a *Admin) checkKeys(c *checker, inWallet func(addr string) bool) {
+	c.checkKey("adminPrivKey", a.AdminPrivKey, "adminPubKey", a.AdminPubKey, "", "")
+	c.inWallet("senderAddr", a.SenderAddr, inWallet)
+}
+
+// checkPorts reports the monitors that connect to the same local RPC port.
+// Remote endpoints may be shared, e.g. by the devices behind one gateway node.
+func checkPorts(checked []*checkedFile) {
+	users := map[int][]*checkedFile{}
+	var ports []int
+	for _, f := range checked {
+		if f.monitor == nil || len(f.monitor.Endpoints) > 0 {
+			continue
+		}
+		port := f.monitor.RpcPort
+		if len(users[port]) == 0 {
+			ports = append(ports, port)
+		}
+		users[port] = append(users[port], f)
+	}
+	for _, port := range ports {
+		if len(users[port]) < 2 {
+			continue
+		}
+		for _, f := range users[port] {
+			var others []string
+			for _, other := range users[port] {
+				if other != f {
+					others = append(others, other.checker.file)
+				}
+			}
+			f.checker.add("rpcPort", "", "port %d is also used by %s", port, strings.Join(others, ", "))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const node2Addr = "dWNrwKvATvPNXNtNNXSj1yzMGerxRQhwUw"
+
+func TestCheck_sampleConfigs(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(".."))
+	defer os.Chdir(wd)
+
+	reports, err := Check([]string{"conf", "setup/default.conf"}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, reports, 9)
+	kinds := map[string]string{}
+	for _, report := range reports {
+		assert.True(t, report.OK(), report.Errors.Error())
+		kinds[report.File] = report.Kind
+	}
+	assert.Equal(t, KindCommon, kinds[filepath.Join("conf", "common.conf")])
+	assert.Equal(t, KindMonitor, kinds[filepath.Join("conf", "node1.conf")])
+	assert.Equal(t, KindAdmin, kinds["setup/default.conf"])
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		// the private key of node1 with a public key of its own
+		"a.conf": `{
+    "rpcPort"       : 50051,
+    "tls"           : {"insecure": true},
+    "senderAddr"    : "` + node1Addr + `",
+    "nodePubKey"    : "` + node1PubKey[:126] + `00",
+    "nodePrivateKey": "` + node1PrivKey + `"
+}`,
+		// the public key of node1 with the address of node2, on the port of a.conf
+		"b.conf": `{
+    "rpcPort"       : 50051,
+    "tls"           : {"insecure": true},
+    "senderAddr"    : "` + node2Addr + `",
+    "nodeAddr"      : "` + node2Addr + `",
+    "nodePubKey"    : "` + node1PubKey + `",
+    "nodeKeystore"  : "b.conf"
+}`,
+		// the same port as a.conf, but on a remote node
+		"c.conf": `{
+    "rpcPort"       : 50051,
+    "endpoints"     : ["gateway.example.com:50051"],
+    "tls"           : {"insecure": true},
+    "senderAddr"    : "` + node1Addr + `",
+    "nodePrivateKey": "` + node1PrivKey + `"
+}`,
+		"common.conf": `{"contractAddr": "` + contractAddr + `"}`,
+		"ignored.txt": `{}`,
+	}
+	for name, content := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	reports, err := Check([]string{"."}, func(addr string) bool { return addr == node1Addr })
+	assert.Nil(t, err)
+	problems := map[string][]string{}
+	for _, report := range reports {
+		for _, e := range report.Errors {
+			problems[report.File] = append(problems[report.File], e.Field)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"a.conf": {"nodePubKey", "rpcPort"},
+		"b.conf": {"nodeAddr", "senderAddr", "rpcPort"},
+	}, problems)
+	assert.Equal(t, "a.conf: 2 problem(s) (monitor config)", reports[0].String())
+	assert.Equal(t, "c.conf: ok (monitor config)", reports[2].String())
+	assert.Equal(t, KindCommon, reports[3].Kind)
+}
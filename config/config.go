@@ -0,0 +1,128 @@
+// Package config loads the config files of the monitor, setup and deploy programs.
+//
+// Files are decoded strictly: an unknown field, such as a misspelled key, is an
+// error instead of being silently ignored. The decoded config is then validated,
+// and every problem found is reported with the file and, where it can be located,
+// the line and column it was found at.
+package config
+
+import (
+	"github.com/dappley/iot-security/blocks"
+	"github.com/dappley/iot-security/budget"
+	"github.com/dappley/iot-security/confirm"
+	"github.com/dappley/iot-security/measure"
+	"github.com/dappley/iot-security/retry"
+	"github.com/dappley/iot-security/rpcclient"
+	"github.com/dappley/iot-security/signer"
+)
+
+// Connection is how a program reaches its dappley node
+type Connection struct {
+	RpcPort   int
+	Endpoints []string
+	TLS       rpcclient.TLSConfig
+}
+
+// Dial connects to the configured endpoints, or to localhost:RpcPort if there are none
+func (c Connection) Dial() (*rpcclient.Pool, error) {
+	return rpcclient.NewPool(
+		rpcclient.Targets(c.Endpoints, c.RpcPort),
+		c.TLS,
+		rpcclient.BlockchainInfoCheck,
+		rpcclient.DefaultHealthCheckInterval,
+	)
+}
+
+// Monitor is the config of the device monitor, e.g. conf/node1.conf
+type Monitor struct {
+	MonitorPath    string
+	Paths          []measure.Policy
+	SenderAddr     string
+	Amount         uint64
+	Tip            uint64
+	Budget         budget.Config
+	RpcPort        int
+	Endpoints      []string
+	NodeAddr       string
+	NodePubkey     string `json:"nodePubKey"`
+	NodePrivateKey string
+	NodeKeystore   string
+	PassphraseFile string
+	Signer         signer.Config
+	StateDir       string
+	Collectors     []string
+	Retry          retry.Config
+	TLS            rpcclient.TLSConfig
+	Blocks         blocks.Config
+}
+
+func (m Monitor) Connection() Connection {
+	return Connection{RpcPort: m.RpcPort, Endpoints: m.Endpoints, TLS: m.TLS}
+}
+
+// Common is the config shared by all monitors of a fleet, i.e. conf/common.conf
+type Common struct {
+	ContractAddr string
+	// ContractSha256 is the SHA-256 of the source of the deployed contract, written by deploy
+	ContractSha256 string
+}
+
+// Admin is the config of setup and deploy, which share setup/default.conf
+type Admin struct {
+	RpcPort        int
+	Endpoints      []string
+	SenderAddr     string
+	Amount         uint64
+	Tip            uint64
+	ContractAddr   string
+	ContractSha256 string
+	AdminPubKey    string
+	AdminPrivKey   string
+	AdminKeystore  string
+	PassphraseFile string
+	Signer         signer.Config
+	Addresses      []string
+	Retry          retry.Config
+	TLS            rpcclient.TLSConfig
+	Confirm        confirm.Config
+}
+
+func (a Admin) Connection() Connection {
+	return Connection{RpcPort: a.RpcPort, Endpoints: a.Endpoints, TLS: a.TLS}
+}
+
+// LoadMonitor reads the monitor config from the file at path, if path is not empty, and from
+// the overrides, if they are not nil. The config is only returned if it is valid.
+func LoadMonitor(path string, overrides *Overrides) (Monitor, error) {
+	var config Monitor
+	if err := load(path, &config, overrides); err != nil {
+		return Monitor{}, err
+	}
+	return config, nil
+}
+
+func LoadCommon(path string, overrides *Overrides) (Common, error) {
+	var config Common
+	if err := load(path, &config, overrides); err != nil {
+		return Common{}, err
+	}
+	return config, nil
+}
+
+func LoadAdmin(path string, overrides *Overrides) (Admin, error) {
+	var config Admin
+	if err := load(path, &config, overrides); err != nil {
+		return Admin{}, err
+	}
+	return config, nil
+}
+
+// LoadContractAdmin reads the admin config like LoadAdmin, and also requires contractAddr.
+// It is used by the commands that call a deployed contract.
+func LoadContractAdmin(path string, overrides *Overrides) (Admin, error) {
+	var config contractAdmin
+	if err := load(path, &config, overrides); err != nil {
+		return Admin{}, err
+	}
+	return Admin(config), nil
+}
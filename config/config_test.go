@@ -0,0 +1,236 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	node1Addr    = "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
+	node1PubKey  = "fd2681827b0e3be73d21e3238b155fce269d7c356f2b85a74a6b0bf6514cbd345dc848a7dad99d7d61dbd8a5e08ac0b21a52b8fc575e29af6f9e089b1bbb7c82"
+	node1PrivKey = "f22bac4a73a9881d523075d9bb749ca537c7fa451366d935bcb65509968ac3e4"
+	contractAddr = "ce8FVBHVaUeZtP6HwMscP3nzSyS2ux1kGT"
+)
+
+func writeConfig(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "node.conf")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestLoad_sampleConfigs(t *testing.T) {
+	// the sample configs use paths relative to the project root folder
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(".."))
+	defer os.Chdir(wd)
+
+	nodeConfigs, err := filepath.Glob("conf/node*.conf")
+	assert.Nil(t, err)
+	for _, path := range append(nodeConfigs, "conf/default.conf") {
+		_, err := LoadMonitor(path, nil)
+		assert.Nil(t, err, path)
+	}
+	common, err := LoadCommon("conf/common.conf", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, contractAddr, common.ContractAddr)
+	admin, err := LoadAdmin("setup/default.conf", nil)
+	assert.Nil(t, err)
+	assert.Len(t, admin.Addresses, 4)
+}
+
+func TestLoad_syntaxError(t *testing.T) {
+	// conf/node5.conf as it used to be, with a missing comma after monitorPath
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"       : 50054,
+    "tls"           : {"insecure": true},
+    "monitorPath"   : "monitored_foler/4"
+    "senderAddr"    : "dWhJ1h33pC2qoqqFQcVpQVD8bPdFZP2h5B"
+}`)
+	defer cleanup()
+
+	_, err := LoadMonitor(path, nil)
+	assert.Equal(t, errs.KindConfig, errs.KindOf(err))
+	list := Errors(err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, 5, list[0].Line)
+	assert.Equal(t, 5, list[0].Column)
+	assert.Contains(t, list[0].Error(), path+":5:5: invalid character")
+}
+
+func TestLoad_unknownField(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"       : 50051,
+    "tls"           : {"insecure": true},
+    "monitoredPath" : "/etc",
+    "senderAddr"    : "`+node1Addr+`"
+}`)
+	defer cleanup()
+
+	_, err := LoadMonitor(path, nil)
+	list := Errors(err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "monitoredPath", list[0].Field)
+	assert.Equal(t, "unknown field", list[0].Msg)
+	assert.Equal(t, 4, list[0].Line)
+	assert.Equal(t, 5, list[0].Column)
+}
+
+func TestLoad_typeError(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "tls"     : {"insecure": true},
+    "rpcPort" : "50051"
+}`)
+	defer cleanup()
+
+	_, err := LoadMonitor(path, nil)
+	list := Errors(err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, 3, list[0].Line)
+	assert.Contains(t, list[0].Msg, "expected a int, found a JSON string")
+}
+
+func TestLoad_trailingData(t *testing.T) {
+	path, cleanup := writeConfig(t, `{"contractAddr": "`+contractAddr+`"}
+{"contractAddr": "`+contractAddr+`"}`)
+	defer cleanup()
+
+	_, err := LoadCommon(path, nil)
+	list := Errors(err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, 2, list[0].Line)
+}
+
+func TestLoadMonitor_validation(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "endpoints"     : ["gateway.example.com:70000", "gateway.example.com"],
+    "tls"           : {"certFile": "device.pem"},
+    "paths"         : [{"path": "/does/not/exist"}, {"path": "/", "change": "sometimes"}],
+    "senderAddr"    : "`+contractAddr+`",
+    "nodeAddr"      : "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEQ",
+    "nodePubKey"    : "fd2681",
+    "nodePrivateKey": "`+node1PrivKey+`",
+    "collectors"    : ["filetree", "processes"]
+}`)
+	defer cleanup()
+
+	_, err := LoadMonitor(path, nil)
+	assert.Equal(t, errs.KindConfig, errs.KindOf(err))
+	lines := map[string]int{}
+	for _, e := range Errors(err) {
+		lines[e.Field] = e.Line
+	}
+	assert.Equal(t, map[string]int{
+		"endpoints[0]":  2,
+		"endpoints[1]":  2,
+		"tls.caFile":    3,
+		"tls.certFile":  3,
+		"paths[0].path": 4,
+		"paths[1]":      4,
+		"senderAddr":    5,
+		"nodeAddr":      6,
+		"nodePubKey":    7,
+		"collectors[1]": 9,
+	}, lines)
+}
+
+func TestLoadMonitor_overlappingPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paths")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "ssh"), 0755))
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"       : 50051,
+    "tls"           : {"insecure": true},
+    "senderAddr"    : "`+node1Addr+`",
+    "nodePrivateKey": "`+node1PrivKey+`",
+    "paths"         : [{"path": "`+dir+`"},
+                       {"path": "`+filepath.Join(dir, "ssh")+`"}]
+}`)
+	defer cleanup()
+
+	_, err = LoadMonitor(path, nil)
+	list := Errors(err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "paths[1].path", list[0].Field)
+		assert.Equal(t, 7, list[0].Line)
+	}
+}
+
+func TestLoadMonitor_nodeKey(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"    : 50051,
+    "tls"        : {"insecure": true},
+    "senderAddr" : "`+node1Addr+`",
+    "nodePubKey" : "`+node1PubKey+`"
+}`)
+	defer cleanup()
+
+	_, err := LoadMonitor(path, nil)
+	list := Errors(err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "nodeKeystore", list[0].Field)
+}
+
+func TestLoadAdmin_validation(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"    : 0,
+    "tls"        : {"insecure": true},
+    "senderAddr" : "`+node1Addr+`",
+    "signer"     : {"type": "tpm"},
+    "addresses"  : ["`+node1Addr+`",
+                    "`+node1Addr+`"]
+}`)
+	defer cleanup()
+
+	_, err := LoadAdmin(path, nil)
+	var fields []string
+	for _, e := range Errors(err) {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{"rpcPort", "signer.type", "addresses[1]"}, fields)
+}
+
+func TestLoadContractAdmin(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"    : 50051,
+    "tls"        : {"insecure": true},
+    "senderAddr" : "`+node1Addr+`"
+}`)
+	defer cleanup()
+
+	_, err := LoadAdmin(path, nil)
+	assert.Nil(t, err)
+
+	_, err = LoadContractAdmin(path, nil)
+	list := Errors(err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "contractAddr", list[0].Field)
+		assert.Equal(t, path+": contractAddr: is required", list[0].Error())
+	}
+
+	overrides, err := testOverrides(Admin{}, nil, "-contract-addr", contractAddr)
+	assert.Nil(t, err)
+	admin, err := LoadContractAdmin(path, overrides)
+	assert.Nil(t, err)
+	assert.Equal(t, contractAddr, admin.ContractAddr)
+	assert.Equal(t, node1Addr, admin.SenderAddr)
+}
+
+func TestCheckAddress(t *testing.T) {
+	assert.Nil(t, CheckUserAddress(node1Addr))
+	assert.Nil(t, CheckContractAddress(contractAddr))
+
+	assert.EqualError(t, CheckUserAddress(contractAddr), "this is a contract address, expected a user address")
+	assert.EqualError(t, CheckContractAddress(node1Addr), "this is a user address, expected a contract address")
+	assert.EqualError(t, CheckUserAddress("dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEQ"), "the address checksum does not match, it may have a typo")
+	assert.EqualError(t, CheckUserAddress("dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2Ed"), "not a dappley address: it decodes to 24 bytes instead of 25")
+	assert.EqualError(t, CheckUserAddress("dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdE0"), "not a base58 address")
+}
@@ -0,0 +1,485 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FormatOf returns the format of a config file from its extension.
+// Files that are not .yaml, .yml or .toml files are JSON, like the .conf files.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// Extension returns the file extension of format
+func Extension(format string) string {
+	switch format {
+	case FormatYAML:
+		return ".yaml"
+	case FormatTOML:
+		return ".toml"
+	}
+	return ".conf"
+}
+
+// Convert converts a config file from one format into another, keeping the order of its keys.
+// Comments are not kept.
+func Convert(data []byte, from string, to string) ([]byte, error) {
+	doc, err := decodeDocument(data, from)
+	if err != nil {
+		return nil, err
+	}
+	out, err := encodeDocument(doc, to)
+	if err != nil {
+		return nil, err
+	}
+	// the converted file must decode into the same config
+	converted, err := decodeDocument(out, to)
+	if err != nil {
+		return nil, fmt.Errorf("the converted config can not be decoded: %v", err)
+	}
+	if !sameValues(doc, converted) {
+		return nil, errors.New("the converted config does not hold the same values")
+	}
+	return out, nil
+}
+
+// sameValues reports whether two documents hold the same values. TOML may change the order of the keys.
+func sameValues(a object, b object) bool {
+	var values [2]interface{}
+	for i, doc := range []object{a, b} {
+		data, err := json.Marshal(doc)
+		if err != nil || json.Unmarshal(data, &values[i]) != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(values[0], values[1])
+}
+
+func encodeDocument(doc object, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		out, err := json.MarshalIndent(doc, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	case FormatYAML:
+		return yaml.Marshal(doc.yaml())
+	case FormatTOML:
+		return doc.toml()
+	}
+	return nil, fmt.Errorf("unknown config format %q", format)
+}
+
+// object is a decoded config document that keeps the order of its keys.
+// Its values are objects, []interface{}, strings, json.Numbers, bools and nil.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o object) keys() []string {
+	keys := make([]string, len(o))
+	for i, m := range o {
+		keys[i] = m.key
+	}
+	return keys
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func decodeDocument(data []byte, format string) (object, error) {
+	switch format {
+	case FormatYAML:
+		return decodeYAML(data)
+	case FormatTOML:
+		return decodeTOML(data)
+	}
+	return decodeJSON(data)
+}
+
+func decodeJSON(data []byte) (object, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	value, err := jsonValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := value.(object)
+	if !ok {
+		return nil, errors.New("the config is not a JSON object")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, &trailingDataError{offset: decoder.InputOffset()}
+	}
+	return doc, nil
+}
+
+func jsonValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		doc := object{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := jsonValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, member{key.(string), value})
+		}
+		_, err := decoder.Token()
+		return doc, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := jsonValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+func decodeYAML(data []byte) (object, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	value, err := fromYAML(doc)
+	if err != nil {
+		return nil, err
+	}
+	return value.(object), nil
+}
+
+func fromYAML(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		doc := object{}
+		for _, item := range v {
+			key, ok := item.Key.(string)
+			if !ok {
+				return nil, fmt.Errorf("the key %v is not a string", item.Key)
+			}
+			value, err := fromYAML(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, member{key, value})
+		}
+		return doc, nil
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range v {
+			value, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case int:
+		return json.Number(strconv.Itoa(v)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case string, bool, nil:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+func decodeTOML(data []byte) (object, error) {
+	var doc map[string]interface{}
+	meta, err := toml.Decode(string(data), &doc)
+	if err != nil {
+		return nil, err
+	}
+	// TOML tables are decoded into maps, so the order of the keys is taken from the metadata
+	order := map[string]int{}
+	for i, key := range meta.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+	value, err := fromTOML(doc, "", order)
+	if err != nil {
+		return nil, err
+	}
+	return value.(object), nil
+}
+
+func fromTOML(value interface{}, path string, order map[string]int) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		sort.SliceStable(keys, func(i, j int) bool {
+			return order[joinKey(path, keys[i])] < order[joinKey(path, keys[j])]
+		})
+		doc := object{}
+		for _, key := range keys {
+			value, err := fromTOML(v[key], joinKey(path, key), order)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, member{key, value})
+		}
+		return doc, nil
+	case []map[string]interface{}:
+		list := []interface{}{}
+		for _, item := range v {
+			value, err := fromTOML(item, path, order)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range v {
+			value, err := fromTOML(item, path, order)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case string, bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+func joinKey(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// yaml returns the document as a yaml.MapSlice, which keeps the order of the keys
+func (o object) yaml() yaml.MapSlice {
+	doc := yaml.MapSlice{}
+	for _, m := range o {
+		doc = append(doc, yaml.MapItem{Key: m.key, Value: toYAML(m.value)})
+	}
+	return doc
+}
+
+func toYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case object:
+		return v.yaml()
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = toYAML(item)
+		}
+		return list
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// toml writes the scalars and lists of the document first, then its objects as tables and its lists of objects as arrays of tables
+func (o object) toml() ([]byte, error) {
+	var b bytes.Buffer
+	var tables []member
+	for _, m := range o {
+		if isTable(m.value) {
+			tables = append(tables, m)
+			continue
+		}
+		value, err := tomlValue(m.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.key, err)
+		}
+		fmt.Fprintf(&b, "%s = %s\n", tomlKey(m.key), value)
+	}
+	for _, table := range tables {
+		var items []object
+		header := "[%s]"
+		if list, ok := table.value.([]interface{}); ok {
+			header = "[[%s]]"
+			for _, item := range list {
+				items = append(items, item.(object))
+			}
+		} else {
+			items = []object{table.value.(object)}
+		}
+		for _, item := range items {
+			fmt.Fprintf(&b, "\n"+header+"\n", tomlKey(table.key))
+			for _, m := range item {
+				value, err := tomlValue(m.value)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %v", table.key, m.key, err)
+				}
+				fmt.Fprintf(&b, "%s = %s\n", tomlKey(m.key), value)
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// isTable reports whether a top level value is written as a table or an array of tables
+func isTable(value interface{}) bool {
+	switch v := value.(type) {
+	case object:
+		return true
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(object); !ok {
+				return false
+			}
+		}
+		return len(v) > 0
+	}
+	return false
+}
+
+func tomlValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case object:
+		var members []string
+		for _, m := range v {
+			value, err := tomlValue(m.value)
+			if err != nil {
+				return "", err
+			}
+			members = append(members, tomlKey(m.key)+" = "+value)
+		}
+		return "{" + strings.Join(members, ", ") + "}", nil
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			value, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case string:
+		return tomlString(v), nil
+	case json.Number:
+		return string(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", errors.New("TOML has no null value")
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
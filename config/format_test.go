@@ -0,0 +1,157 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nodeJSON = `{
+    "rpcPort"       : 50051,
+    "tls"           : {"insecure": true},
+    "paths"         : [{"path": "/etc", "exclude": ["*.swp"], "change": "immutable"}],
+    "retry"         : {"jitter": 0.2},
+    "senderAddr"    : "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A",
+    "nodePrivateKey": "` + node1PrivKey + `"
+}`
+
+func TestConvert(t *testing.T) {
+	yamlData, err := Convert([]byte(nodeJSON), FormatJSON, FormatYAML)
+	assert.Nil(t, err)
+	assert.Equal(t, `rpcPort: 50051
+tls:
+  insecure: true
+paths:
+- path: /etc
+  exclude:
+  - '*.swp'
+  change: immutable
+retry:
+  jitter: 0.2
+senderAddr: dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A
+nodePrivateKey: `+node1PrivKey+`
+`, string(yamlData))
+
+	tomlData, err := Convert(yamlData, FormatYAML, FormatTOML)
+	assert.Nil(t, err)
+	assert.Equal(t, `rpcPort = 50051
+senderAddr = "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A"
+nodePrivateKey = "`+node1PrivKey+`"
+
+[tls]
+insecure = true
+
+[[paths]]
+path = "/etc"
+exclude = ["*.swp"]
+change = "immutable"
+
+[retry]
+jitter = 0.2
+`, string(tomlData))
+
+	jsonData, err := Convert(tomlData, FormatTOML, FormatJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+    "rpcPort": 50051,
+    "senderAddr": "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A",
+    "nodePrivateKey": "`+node1PrivKey+`",
+    "tls": {
+        "insecure": true
+    },
+    "paths": [
+        {
+            "path": "/etc",
+            "exclude": [
+                "*.swp"
+            ],
+            "change": "immutable"
+        }
+    ],
+    "retry": {
+        "jitter": 0.2
+    }
+}
+`, string(jsonData))
+}
+
+func TestConvert_sampleConfigs(t *testing.T) {
+	files, err := filepath.Glob("../conf/*.conf")
+	assert.Nil(t, err)
+	for _, file := range append(files, "../setup/default.conf") {
+		data, err := ioutil.ReadFile(file)
+		assert.Nil(t, err)
+		for _, format := range []string{FormatYAML, FormatTOML} {
+			_, err := Convert(data, FormatJSON, format)
+			assert.Nil(t, err, file+" to "+format)
+		}
+	}
+}
+
+func TestLoadMonitor_formats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{FormatYAML, FormatTOML} {
+		data, err := Convert([]byte(nodeJSON), FormatJSON, format)
+		assert.Nil(t, err)
+		path := filepath.Join(dir, "node"+Extension(format))
+		assert.Nil(t, ioutil.WriteFile(path, data, 0644))
+		config, err := LoadMonitor(path, nil)
+		assert.Nil(t, err, format)
+		assert.Equal(t, 50051, config.RpcPort)
+		assert.Equal(t, []string{"*.swp"}, config.Paths[0].Exclude)
+		assert.Equal(t, 0.2, config.Retry.Jitter)
+	}
+}
+
+func TestLoadMonitor_formatErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"node.yaml": `# node 1
+rpcPort: 50051
+tls:
+  insecure: true
+senderAddr: dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A
+nodePrivateKey: ` + node1PrivKey + `
+monitoredPath: /etc
+`,
+		"node.toml": `# node 1
+rpcPort = "50051"
+senderAddr = "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A"
+nodePrivateKey = "` + node1PrivKey + `"
+
+[tls]
+insecure = true
+`,
+		"syntax.yaml": `rpcPort: 50051
+tls:
+  insecure: true
+ senderAddr: dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A
+`,
+		"syntax.toml": `rpcPort = 50051
+senderAddr = dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A
+`,
+	}
+	expected := map[string]string{
+		"node.yaml":   "node.yaml:7:1: monitoredPath: unknown field",
+		"node.toml":   "node.toml:2:1: rpcPort: expected a int, found a JSON string",
+		"syntax.yaml": "syntax.yaml:3: yaml: line 3: did not find expected key",
+		"syntax.toml": "syntax.toml:2: Near line 2",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+		_, err := LoadMonitor(path, nil)
+		list := Errors(err)
+		if assert.Len(t, list, 1, name) {
+			assert.Contains(t, list[0].Error(), filepath.Join(dir, expected[name]))
+		}
+	}
+}
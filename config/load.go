@@ -0,0 +1,275 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dappley/iot-security/errs"
+)
+
+// Error is one problem found in a config file. Line and Column are 0 if they are not known.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	// Field is the path of the offending field, e.g. "tls.caFile" or "addresses[2]"
+	Field string
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	msg := e.Msg
+	if e.Field != "" {
+		msg = e.Field + ": " + msg
+	}
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, msg)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, msg)
+	}
+	return msg
+}
+
+// ErrorList holds every problem found in one config file
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Errors returns the problems found in a config file from an error returned by a Load function
+func Errors(err error) ErrorList {
+	var list ErrorList
+	if errors.As(err, &list) {
+		return list
+	}
+	return nil
+}
+
+type validator interface {
+	validate(c *checker)
+}
+
+func load(path string, config validator, overrides *Overrides) error {
+	c, err := parse(path, config, overrides)
+	if err != nil {
+		return err
+	}
+	if len(c.errors) > 0 {
+		return errs.Config("load config", c.errors)
+	}
+	return nil
+}
+
+// parse decodes the file at path, if any, applies the overrides, if any, and validates the result.
+// Only an unreadable file is returned as an error, the problems found are collected in the checker.
+func parse(path string, config validator, overrides *Overrides) (*checker, error) {
+	c := &checker{file: path, format: FormatOf(path)}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errs.Config("read config", err)
+		}
+		c.data = data
+		if c.format != FormatJSON {
+			// YAML and TOML files are converted to JSON, so that every format is decoded as strictly
+			doc, err := decodeDocument(data, c.format)
+			if err != nil {
+				c.documentError(err)
+				return c, nil
+			}
+			if data, err = json.Marshal(doc); err != nil {
+				c.documentError(err)
+				return c, nil
+			}
+		}
+		if err := decode(data, config); err != nil {
+			c.decodeError(err)
+			return c, nil
+		}
+	}
+	if overrides != nil {
+		c.sources = overrides.apply(config, c)
+		if len(c.errors) > 0 {
+			return c, nil
+		}
+	}
+	config.validate(c)
+	return c, nil
+}
+
+func decode(data []byte, config interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return &trailingDataError{offset: decoder.InputOffset()}
+	}
+	return nil
+}
+
+type trailingDataError struct {
+	offset int64
+}
+
+func (e *trailingDataError) Error() string { return "unexpected data after the config object" }
+
+// checker collects the problems found in one file and locates them in its content
+type checker struct {
+	file   string
+	format string
+	data   []byte
+	// sources tells where the overridden fields were set
+	sources map[string]string
+	errors  ErrorList
+}
+
+func (c *checker) decodeError(err error) {
+	const unknownField = "json: unknown field "
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		c.addAt(e.Offset-1, "", e.Error())
+	case *json.UnmarshalTypeError:
+		msg := fmt.Sprintf("expected a %s, found a JSON %s", e.Type, e.Value)
+		if e.Field == "" {
+			c.addAt(e.Offset-1, "", msg)
+			return
+		}
+		c.add(e.Field, "", msg)
+	case *trailingDataError:
+		c.addAt(e.offset, "", e.Error())
+	default:
+		switch {
+		case err == io.EOF:
+			c.addAt(0, "", "the file is empty")
+		case err == io.ErrUnexpectedEOF:
+			c.addAt(int64(len(c.data)), "", "unexpected end of file")
+		case strings.HasPrefix(err.Error(), unknownField):
+			name, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), unknownField))
+			if unquoteErr != nil {
+				name = strings.TrimPrefix(err.Error(), unknownField)
+			}
+			c.add(name, "", "unknown field")
+		default:
+			c.errors = append(c.errors, &Error{File: c.file, Msg: err.Error()})
+		}
+	}
+}
+
+var errorLine = regexp.MustCompile(`(?i)line (\d+)`)
+
+// documentError reports an error of the YAML or TOML parser, at the line it names
+func (c *checker) documentError(err error) {
+	e := &Error{File: c.file, Msg: err.Error()}
+	if m := errorLine.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+	}
+	c.errors = append(c.errors, e)
+}
+
+// add reports a problem with field. The problem is located at value if it is given and
+// found in the file, and otherwise at the key of the field. A problem with a field that
+// has been overridden is reported at the flag or environment variable that set it.
+func (c *checker) add(field string, value string, format string, args ...interface{}) {
+	err := &Error{File: c.file, Field: field, Msg: fmt.Sprintf(format, args...)}
+	if source := c.source(field); source != "" {
+		err.File = source
+	} else if offset := c.locate(field, value); offset >= 0 {
+		err.Line, err.Column = position(c.data, offset)
+	}
+	c.errors = append(c.errors, err)
+}
+
+// source returns the flag or environment variable that set field or the object or list holding it
+func (c *checker) source(field string) string {
+	path := strings.ToLower(listIndex.ReplaceAllString(field, ""))
+	for path != "" {
+		if source, ok := c.sources[path]; ok {
+			return source
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return ""
+}
+
+// keyPattern matches the key in a file of format. Its first group starts at the key.
+// Keys match case-insensitively, as they do when the file is decoded.
+func keyPattern(format string, key string) *regexp.Regexp {
+	key = regexp.QuoteMeta(key)
+	switch format {
+	case FormatYAML:
+		return regexp.MustCompile(`(?im)^[\s-]*(["']?` + key + `["']?\s*:)`)
+	case FormatTOML:
+		return regexp.MustCompile(`(?im)(?:^|[{,\[\s])(["']?` + key + `["']?\s*(?:=|\]))`)
+	}
+	return regexp.MustCompile(`(?i)("` + key + `"\s*:)`)
+}
+
+var listIndex = regexp.MustCompile(`\[\d+\]`)
+
+// addAt reports a problem at an offset of the decoded JSON, which is only known for JSON files
+func (c *checker) addAt(offset int64, field string, msg string) {
+	err := &Error{File: c.file, Field: field, Msg: msg}
+	if c.format == FormatJSON {
+		err.Line, err.Column = position(c.data, offset)
+	}
+	c.errors = append(c.errors, err)
+}
+
+// locate finds value, or else the key of field. A missing key is located at the key of its parent, e.g. tls for tls.caFile.
+func (c *checker) locate(field string, value string) int64 {
+	if value != "" {
+		if i := bytes.Index(c.data, []byte(value)); i >= 0 {
+			return int64(i)
+		}
+	}
+	for field != "" {
+		key := field
+		if i := strings.LastIndex(field, "."); i >= 0 {
+			key, field = field[i+1:], field[:i]
+		} else {
+			field = ""
+		}
+		if i := strings.Index(key, "["); i >= 0 {
+			key = key[:i]
+		}
+		if loc := keyPattern(c.format, key).FindSubmatchIndex(c.data); loc != nil {
+			return int64(loc[2])
+		}
+	}
+	return -1
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
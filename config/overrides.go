@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix starts the name of every environment variable read by the programs
+const EnvPrefix = "IOTSEC_"
+
+// Environment variables with the paths of the config files
+const (
+	EnvConfig       = EnvPrefix + "CONFIG"
+	EnvCommonConfig = EnvPrefix + "COMMON_CONFIG"
+)
+
+// FilePath returns the config file to read: path if it is set, else the file named by the
+// environment variable env, else def if that file exists. It returns "" if there is no file to read.
+func FilePath(path string, env string, def string) string {
+	if path != "" {
+		return path
+	}
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	if _, err := os.Stat(def); err == nil {
+		return def
+	}
+	return ""
+}
+
+// Overrides sets config fields from command line flags and IOTSEC_* environment variables.
+// Every field is named after its key in the config file: tls.caFile is set by the flag
+// -tls-ca-file and by the environment variable IOTSEC_TLS_CA_FILE. A flag takes precedence
+// over the environment variable, which takes precedence over the config file.
+//
+// Lists of strings are given comma-separated, other lists as JSON, e.g.
+// IOTSEC_PATHS='[{"path": "/etc", "change": "immutable"}]'.
+type Overrides struct {
+	fields    []*field
+	lookupEnv func(key string) (string, bool)
+}
+
+type field struct {
+	// path is the key of the field in the config file, e.g. tls.caFile
+	path  string
+	flag  string
+	env   string
+	index []int
+	kind  reflect.Kind
+	// value is the flag value, if set is true
+	value string
+	set   bool
+}
+
+// NewOverrides creates the overrides of the fields of config, e.g. Monitor{}
+func NewOverrides(config interface{}) *Overrides {
+	o := &Overrides{lookupEnv: os.LookupEnv}
+	o.addFields(reflect.TypeOf(config), nil, nil)
+	return o
+}
+
+func (o *Overrides) addFields(t reflect.Type, index []int, keys []string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldKeys := append(append([]string{}, keys...), keyName(f))
+		if f.Type.Kind() == reflect.Struct {
+			o.addFields(f.Type, fieldIndex, fieldKeys)
+			continue
+		}
+		var words []string
+		for _, key := range fieldKeys {
+			words = append(words, splitWords(key)...)
+		}
+		o.fields = append(o.fields, &field{
+			path:  strings.Join(fieldKeys, "."),
+			flag:  strings.ToLower(strings.Join(words, "-")),
+			env:   EnvPrefix + strings.ToUpper(strings.Join(words, "_")),
+			index: fieldIndex,
+			kind:  f.Type.Kind(),
+		})
+	}
+}
+
+// keyName returns the key of a struct field in the config file, e.g. rpcPort for RpcPort and tls for TLS
+func keyName(f reflect.StructField) string {
+	if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	runes := []rune(f.Name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// the last capital of a leading acronym starts the next word, as in URLPath
+	if upper > 1 && upper < len(runes) {
+		upper--
+	}
+	return strings.ToLower(string(runes[:upper])) + string(runes[upper:])
+}
+
+// splitWords splits a camelCase key into its words
+func splitWords(key string) []string {
+	var words []string
+	start := 0
+	runes := []rune(key)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1]) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// Register adds a flag for every field to flags
+func (o *Overrides) Register(flags *flag.FlagSet) {
+	for _, f := range o.fields {
+		flags.Var((*fieldFlag)(f), f.flag, fmt.Sprintf("overrides %s (env %s)", f.path, f.env))
+	}
+}
+
+type fieldFlag field
+
+func (f *fieldFlag) String() string { return f.value }
+
+func (f *fieldFlag) Set(value string) error {
+	f.value = value
+	f.set = true
+	return nil
+}
+
+func (f *fieldFlag) IsBoolFlag() bool { return f.kind == reflect.Bool }
+
+// apply sets the overridden fields of config, which points to the config struct.
+// It returns where each overridden field was set, keyed by the lower case path of the field.
+func (o *Overrides) apply(config interface{}, c *checker) map[string]string {
+	sources := map[string]string{}
+	target := reflect.ValueOf(config).Elem()
+	for _, f := range o.fields {
+		var value, source string
+		if env, ok := o.lookupEnv(f.env); ok {
+			value, source = env, "env "+f.env
+		}
+		if f.set {
+			value, source = f.value, "flag -"+f.flag
+		}
+		if source == "" {
+			continue
+		}
+		sources[strings.ToLower(f.path)] = source
+		if err := setValue(target.FieldByIndex(f.index), value); err != nil {
+			c.errors = append(c.errors, &Error{File: source, Field: f.path, Msg: err.Error()})
+		}
+	}
+	return sources
+}
+
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v.OverflowInt(i) {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(i)
+	case reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a positive integer", s)
+		}
+		v.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(s), "[") {
+			var items []string
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items))
+			return nil
+		}
+		list := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), list.Interface()); err != nil {
+			return fmt.Errorf("not a JSON list: %v", err)
+		}
+		v.Set(list.Elem())
+	default:
+		return errors.New("can not be overridden")
+	}
+	return nil
+}
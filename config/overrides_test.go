@@ -0,0 +1,104 @@
+package config
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/dappley/iot-security/measure"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOverrides(config interface{}, env map[string]string, args ...string) (*Overrides, error) {
+	overrides := NewOverrides(config)
+	overrides.lookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides.Register(flags)
+	return overrides, flags.Parse(args)
+}
+
+func TestNewOverrides_names(t *testing.T) {
+	overrides := NewOverrides(Monitor{})
+	names := map[string]string{}
+	for _, f := range overrides.fields {
+		names[f.path] = f.flag + " " + f.env
+	}
+	assert.Equal(t, "rpc-port IOTSEC_RPC_PORT", names["rpcPort"])
+	assert.Equal(t, "node-pub-key IOTSEC_NODE_PUB_KEY", names["nodePubKey"])
+	assert.Equal(t, "tls-ca-file IOTSEC_TLS_CA_FILE", names["tls.caFile"])
+	assert.Equal(t, "budget-degraded-interval IOTSEC_BUDGET_DEGRADED_INTERVAL", names["budget.degradedInterval"])
+	assert.Equal(t, "retry-initial-interval-ms IOTSEC_RETRY_INITIAL_INTERVAL_MS", names["retry.initialIntervalMs"])
+	assert.Equal(t, "paths IOTSEC_PATHS", names["paths"])
+	assert.Len(t, names, len(overrides.fields))
+}
+
+func TestLoadMonitor_overrides(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"       : 50051,
+    "tls"           : {"insecure": false, "caFile": "/does/not/exist"},
+    "senderAddr"    : "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A",
+    "nodePrivateKey": "`+node1PrivKey+`"
+}`)
+	defer cleanup()
+
+	overrides, err := testOverrides(Monitor{}, map[string]string{
+		"IOTSEC_RPC_PORT":    "50052",
+		"IOTSEC_SENDER_ADDR": node1Addr,
+		"IOTSEC_ENDPOINTS":   "gateway1.example.com:50051, gateway2.example.com:50051",
+		"IOTSEC_PATHS":       `[{"path": "/", "change": "mutable"}]`,
+	}, "-rpc-port", "50053", "-tls-insecure", "-retry-jitter", "0.5")
+	assert.Nil(t, err)
+
+	config, err := LoadMonitor(path, overrides)
+	assert.Nil(t, err)
+	assert.Equal(t, 50053, config.RpcPort)
+	assert.Equal(t, node1Addr, config.SenderAddr)
+	assert.Equal(t, []string{"gateway1.example.com:50051", "gateway2.example.com:50051"}, config.Endpoints)
+	assert.Equal(t, []measure.Policy{{Path: "/", Change: measure.PolicyMutable}}, config.Paths)
+	assert.True(t, config.TLS.Insecure)
+	assert.Equal(t, 0.5, config.Retry.Jitter)
+	assert.Equal(t, node1PrivKey, config.NodePrivateKey)
+}
+
+func TestLoadCommon_withoutFile(t *testing.T) {
+	overrides, err := testOverrides(Common{}, map[string]string{"IOTSEC_CONTRACT_ADDR": contractAddr})
+	assert.Nil(t, err)
+	config, err := LoadCommon("", overrides)
+	assert.Nil(t, err)
+	assert.Equal(t, contractAddr, config.ContractAddr)
+
+	_, err = LoadCommon("", nil)
+	assert.EqualError(t, Errors(err), "contractAddr: is required")
+}
+
+func TestLoadMonitor_overrideErrors(t *testing.T) {
+	path, cleanup := writeConfig(t, `{
+    "rpcPort"    : 50051,
+    "tls"        : {"insecure": true},
+    "senderAddr" : "`+node1Addr+`",
+    "nodeKeystore": "/does/not/exist"
+}`)
+	defer cleanup()
+
+	overrides, err := testOverrides(Monitor{}, map[string]string{
+		"IOTSEC_SENDER_ADDR": contractAddr,
+	}, "-node-keystore", "/not/here/either", "-collectors", "filetree,processes")
+	assert.Nil(t, err)
+	_, err = LoadMonitor(path, overrides)
+	var sources []string
+	for _, e := range Errors(err) {
+		sources = append(sources, e.File+" "+e.Field)
+	}
+	assert.Equal(t, []string{
+		"env IOTSEC_SENDER_ADDR senderAddr",
+		"flag -node-keystore nodeKeystore",
+		"flag -collectors collectors[1]",
+	}, sources)
+
+	overrides, err = testOverrides(Monitor{}, nil, "-rpc-port", "local")
+	assert.Nil(t, err)
+	_, err = LoadMonitor(path, overrides)
+	assert.EqualError(t, Errors(err), `flag -rpc-port: rpcPort: "local" is not an integer`)
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SetStrings sets top level string keys of the config file at path, e.g. the contractAddr of a
+// common config after a deploy. Keys that are already in the file are changed in place, so the
+// formatting and the comments of the file are kept. Missing keys are added, and a missing file is
+// created. The file is replaced atomically: a program that reads it at the same time sees either
+// the old or the new file.
+func SetStrings(path string, values map[string]string) error {
+	format := FormatOf(path)
+	perm := os.FileMode(0644)
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		data = nil
+	case err != nil:
+		return err
+	default:
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		perm = info.Mode().Perm()
+	}
+
+	doc := object{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if doc, err = decodeDocument(data, format); err != nil {
+			return fmt.Errorf("can not update %s: %v", path, err)
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		doc = doc.with(key, values[key])
+		data = setString(data, format, key, values[key])
+	}
+
+	// the edited file must hold the same values as the updated document, otherwise it is written anew
+	if updated, err := decodeDocument(data, format); err != nil || !sameValues(doc, updated) {
+		if data, err = encodeDocument(doc, format); err != nil {
+			return fmt.Errorf("can not update %s: %v", path, err)
+		}
+	}
+	return writeFile(path, data, perm)
+}
+
+// with returns the document with key set to value. An existing key is matched case-insensitively, like encoding/json does.
+func (o object) with(key string, value interface{}) object {
+	doc := append(object{}, o...)
+	for i, m := range doc {
+		if strings.EqualFold(m.key, key) {
+			doc[i].value = value
+			return doc
+		}
+	}
+	return append(doc, member{key, value})
+}
+
+// scalarValue matches a quoted or bare scalar after the separator of a key
+var scalarValue = regexp.MustCompile(`^[ \t]*("(?:[^"\\\n]|\\.)*"|'[^'\n]*'|[^\s#,}\]]+)`)
+
+// setString replaces the value of key in data, or adds the key if data does not have it
+func setString(data []byte, format string, key string, value string) []byte {
+	encoded := encodeString(format, value)
+	if loc := keyPattern(format, key).FindSubmatchIndex(data); loc != nil {
+		end := loc[3]
+		if v := scalarValue.FindSubmatchIndex(data[end:]); v != nil {
+			return concat(data[:end+v[2]], []byte(encoded), data[end+v[3]:])
+		}
+		return data
+	}
+
+	switch format {
+	case FormatYAML:
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data, '\n')
+		}
+		return concat(data, []byte(key+": "+encoded+"\n"))
+	case FormatTOML:
+		// top level keys must come before the first table, so the key is added after the last one before it
+		line := []byte(tomlKey(key) + " = " + encoded + "\n")
+		if loc := tableHeader.FindIndex(data); loc != nil {
+			end := len(bytes.TrimRight(data[:loc[0]], " \t\r\n"))
+			if end == 0 {
+				return concat(line, data)
+			}
+			return concat(data[:end], []byte("\n"), line, data[end+1:])
+		}
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data, '\n')
+		}
+		return concat(data, line)
+	}
+
+	// a JSON member is added after the last one, with the indentation of the first one
+	end := bytes.LastIndexByte(data, '}')
+	if end < 0 {
+		return data
+	}
+	indent := "    "
+	if m := jsonIndent.FindSubmatch(data); m != nil {
+		indent = string(m[1])
+	}
+	before := bytes.TrimRight(data[:end], " \t\r\n")
+	separator := ","
+	if bytes.HasSuffix(before, []byte("{")) {
+		separator = ""
+	}
+	return concat(before, []byte(separator+"\n"+indent+`"`+key+`": `+encoded+"\n"), data[end:])
+}
+
+var (
+	tableHeader = regexp.MustCompile(`(?m)^[ \t]*\[`)
+	jsonIndent  = regexp.MustCompile(`\{[ \t]*\r?\n([ \t]+)"`)
+)
+
+func encodeString(format string, value string) string {
+	switch format {
+	case FormatYAML:
+		out, err := yaml.Marshal(value)
+		if err == nil && !bytes.Contains(bytes.TrimSuffix(out, []byte("\n")), []byte("\n")) {
+			return string(bytes.TrimSuffix(out, []byte("\n")))
+		}
+	case FormatTOML:
+		return tomlString(value)
+	}
+	// a JSON string is also a YAML string
+	out, _ := json.Marshal(value)
+	return string(out)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// writeFile writes data to a temporary file in the folder of path and renames it to path,
+// so that path is replaced atomically
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	newContractAddr = "coh8esKj1n6QhuGHnCaP4Yv1quujpvMSMT"
+	contractSha256  = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+)
+
+func TestSetStrings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"common.conf": `{
+    "contractAddr"  : "` + contractAddr + `"
+}`,
+		"common.yaml": `# fleet 1
+contractAddr: ` + contractAddr + ` # deployed by hand
+`,
+		"common.toml": `contractAddr = "` + contractAddr + `"
+
+[tls]
+insecure = true
+`,
+	}
+	expected := map[string]string{
+		"common.conf": `{
+    "contractAddr"  : "` + newContractAddr + `",
+    "contractSha256": "` + contractSha256 + `"
+}`,
+		"common.yaml": `# fleet 1
+contractAddr: ` + newContractAddr + ` # deployed by hand
+contractSha256: ` + contractSha256 + `
+`,
+		"common.toml": `contractAddr = "` + newContractAddr + `"
+contractSha256 = "` + contractSha256 + `"
+
+[tls]
+insecure = true
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+		assert.Nil(t, SetStrings(path, map[string]string{
+			"contractAddr":   newContractAddr,
+			"contractSha256": contractSha256,
+		}))
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, expected[name], string(data), name)
+		info, err := os.Stat(path)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// the temporary files are renamed
+	tmp, err := filepath.Glob(filepath.Join(dir, ".*"))
+	assert.Nil(t, err)
+	assert.Empty(t, tmp)
+}
+
+func TestSetStrings_newFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "common.conf")
+	assert.Nil(t, SetStrings(path, map[string]string{"contractAddr": newContractAddr}))
+	config, err := LoadCommon(path, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, newContractAddr, config.ContractAddr)
+}
+
+func TestSetStrings_rewrite(t *testing.T) {
+	// the key is first found in another object, so the file is written anew
+	path, cleanup := writeConfig(t, `{"tls": {"contractAddr": "x"}, "contractAddr": "`+contractAddr+`"}`)
+	defer cleanup()
+	assert.Nil(t, SetStrings(path, map[string]string{"contractAddr": newContractAddr}))
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+    "tls": {
+        "contractAddr": "x"
+    },
+    "contractAddr": "`+newContractAddr+`"
+}
+`, string(data))
+
+	path, cleanup = writeConfig(t, `{"contractAddr": `)
+	defer cleanup()
+	assert.NotNil(t, SetStrings(path, map[string]string{"contractAddr": newContractAddr}))
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/dappley/iot-security/collector"
+	"github.com/dappley/iot-security/rpcclient"
+	"github.com/dappley/iot-security/signer"
+	"github.com/mr-tron/base58/base58"
+)
+
+// Version bytes of dappley addresses
+const (
+	userAddrVersion     = 0x5a
+	contractAddrVersion = 0x58
+)
+
+const (
+	privKeyHexLen = 64
+	pubKeyHexLen  = 128
+	sha256HexLen  = 64
+)
+
+// CheckUserAddress checks that addr is a well-formed dappley user address, e.g. the address of a wallet or a node
+func CheckUserAddress(addr string) error {
+	return checkAddress(addr, userAddrVersion)
+}
+
+// CheckContractAddress checks that addr is a well-formed dappley contract address
+func CheckContractAddress(addr string) error {
+	return checkAddress(addr, contractAddrVersion)
+}
+
+func checkAddress(addr string, version byte) error {
+	decoded, err := base58.Decode(addr)
+	if err != nil {
+		return errors.New("not a base58 address")
+	}
+	if len(decoded) != 25 {
+		return fmt.Errorf("not a dappley address: it decodes to %d bytes instead of 25", len(decoded))
+	}
+	payload, checksum := decoded[:21], decoded[21:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(checksum, second[:4]) {
+		return errors.New("the address checksum does not match, it may have a typo")
+	}
+	switch {
+	case decoded[0] == version:
+		return nil
+	case decoded[0] == userAddrVersion:
+		return errors.New("this is a user address, expected a contract address")
+	case decoded[0] == contractAddrVersion:
+		return errors.New("this is a contract address, expected a user address")
+	}
+	return fmt.Errorf("unknown address version 0x%02x", decoded[0])
+}
+
+func checkHex(s string, length int) error {
+	if len(s) != length {
+		return fmt.Errorf("expected %d hex characters, found %d", length, len(s))
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return errors.New("not a hex string")
+	}
+	return nil
+}
+
+func checkPort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d is out of range 1-65535", port)
+	}
+	return nil
+}
+
+func (c *checker) userAddress(field string, addr string) {
+	if err := CheckUserAddress(addr); err != nil {
+		c.add(field, addr, "%s: %v", addr, err)
+	}
+}
+
+func (c *checker) contractAddress(field string, addr string) {
+	if err := CheckContractAddress(addr); err != nil {
+		c.add(field, addr, "%s: %v", addr, err)
+	}
+}
+
+func (c *checker) hexKey(field string, key string, length int) {
+	if key == "" {
+		return
+	}
+	if err := checkHex(key, length); err != nil {
+		c.add(field, "", "%v", err)
+	}
+}
+
+func (c *checker) exists(field string, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			c.add(field, path, "%s does not exist", path)
+			return
+		}
+		c.add(field, path, "%v", err)
+	}
+}
+
+func (c *checker) required(field string, value string) bool {
+	if value == "" {
+		c.add(field, "", "is required")
+		return false
+	}
+	return true
+}
+
+func (c *checker) connection(conn Connection) {
+	if len(conn.Endpoints) == 0 {
+		if err := checkPort(conn.RpcPort); err != nil {
+			c.add("rpcPort", "", "%v. Set rpcPort or endpoints", err)
+		}
+	} else if conn.RpcPort != 0 {
+		if err := checkPort(conn.RpcPort); err != nil {
+			c.add("rpcPort", "", "%v", err)
+		}
+	}
+	for i, endpoint := range conn.Endpoints {
+		field := fmt.Sprintf("endpoints[%d]", i)
+		_, portStr, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			c.add(field, endpoint, "%s is not host:port", endpoint)
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			c.add(field, endpoint, "%s: the port is not a number", endpoint)
+			continue
+		}
+		if err := checkPort(port); err != nil {
+			c.add(field, endpoint, "%s: %v", endpoint, err)
+		}
+	}
+	c.tls(conn.TLS)
+}
+
+func (c *checker) tls(config rpcclient.TLSConfig) {
+	if config.Insecure {
+		return
+	}
+	if config.CaFile == "" {
+		c.add("tls.caFile", "", "is required. Set tls.insecure to connect without TLS")
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		c.add("tls.certFile", "", "tls.certFile and tls.keyFile must be set together")
+	}
+	c.exists("tls.caFile", config.CaFile)
+	c.exists("tls.certFile", config.CertFile)
+	c.exists("tls.keyFile", config.KeyFile)
+}
+
+// signer checks the signer config and reports whether it is the software signer
+func (c *checker) signer(config signer.Config) bool {
+	switch config.Type {
+	case "", signer.TypeSoftware:
+		return true
+	case signer.TypePKCS11:
+		c.required("signer.module", config.Module)
+		c.required("signer.tokenLabel", config.TokenLabel)
+		c.required("signer.keyLabel", config.KeyLabel)
+		c.exists("signer.module", config.Module)
+		c.exists("signer.pinFile", config.PinFile)
+	default:
+		c.add("signer.type", config.Type, "unknown signer %q, expected %q or %q", config.Type, signer.TypeSoftware, signer.TypePKCS11)
+	}
+	return false
+}
+
+func (m *Monitor) validate(c *checker) {
+	c.connection(m.Connection())
+	if c.required("senderAddr", m.SenderAddr) {
+		c.userAddress("senderAddr", m.SenderAddr)
+	}
+	if m.NodeAddr != "" {
+		c.userAddress("nodeAddr", m.NodeAddr)
+	}
+	c.hexKey("nodePubKey", m.NodePubkey, pubKeyHexLen)
+	c.hexKey("nodePrivateKey", m.NodePrivateKey, privKeyHexLen)
+	if c.signer(m.Signer) && m.NodeKeystore == "" && m.NodePrivateKey == "" {
+		c.add("nodeKeystore", "", "no node key is configured. Set nodeKeystore or a pkcs11 signer")
+	}
+	c.exists("nodeKeystore", m.NodeKeystore)
+	c.exists("passphraseFile", m.PassphraseFile)
+
+	if m.MonitorPath != "" && len(m.Paths) > 0 {
+		c.add("monitorPath", "", "monitorPath and paths can not both be set")
+	}
+	c.exists("monitorPath", m.MonitorPath)
+	for i, policy := range m.Paths {
+		field := fmt.Sprintf("paths[%d]", i)
+		if err := policy.Validate(); err != nil {
+			c.add(field, policy.Path, "%v", err)
+			continue
+		}
+		c.exists(field+".path", policy.Path)
+		for j, earlier := range m.Paths[:i] {
+			if policy.Overlaps(earlier) {
+				c.add(field+".path", policy.Path, "%s overlaps paths[%d] %s, so its entries would be measured twice", policy.Path, j, earlier.Path)
+				break
+			}
+		}
+	}
+
+	known := map[string]bool{}
+	for _, name := range collector.Names() {
+		known[name] = true
+	}
+	for i, name := range m.Collectors {
+		if !known[name] {
+			c.add(fmt.Sprintf("collectors[%d]", i), "", "unknown collector %q, expected one of %v", name, collector.Names())
+		}
+	}
+}
+
+func (config *Common) validate(c *checker) {
+	if c.required("contractAddr", config.ContractAddr) {
+		c.contractAddress("contractAddr", config.ContractAddr)
+	}
+	c.hexKey("contractSha256", config.ContractSha256, sha256HexLen)
+}
+
+// contractAdmin is the admin config of the commands that call a deployed contract
+type contractAdmin Admin
+
+func (a *contractAdmin) validate(c *checker) {
+	(*Admin)(a).validate(c)
+	c.required("contractAddr", a.ContractAddr)
+}
+
+// validate does not require a contract address or an admin key, because deploy
+// reads the same file before the contract exists and never signs anything.
+func (a *Admin) validate(c *checker) {
+	c.connection(a.Connection())
+	if c.required("senderAddr", a.SenderAddr) {
+		c.userAddress("senderAddr", a.SenderAddr)
+	}
+	if a.ContractAddr != "" {
+		c.contractAddress("contractAddr", a.ContractAddr)
+	}
+	c.hexKey("contractSha256", a.ContractSha256, sha256HexLen)
+	c.hexKey("adminPubKey", a.AdminPubKey, pubKeyHexLen)
+	c.hexKey("adminPrivKey", a.AdminPrivKey, privKeyHexLen)
+	c.signer(a.Signer)
+	c.exists("adminKeystore", a.AdminKeystore)
+	c.exists("passphraseFile", a.PassphraseFile)
+
+	seen := map[string]bool{}
+	for i, addr := range a.Addresses {
+		field := fmt.Sprintf("addresses[%d]", i)
+		if seen[addr] {
+			c.add(field, "", "%s is listed twice", addr)
+			continue
+		}
+		seen[addr] = true
+		c.userAddress(field, addr)
+	}
+}
@@ -0,0 +1,133 @@
+// Package confirm tracks sent transactions until they are included and records their outcome.
+//
+// Every send ends as confirmed, rejected or timed out. The outcome is logged and counted
+// in the tx.<kind>.<outcome> metrics, and the time to confirmation in tx.<kind>.confirmation.
+// Only the sends the node accepted are counted in tx.<kind>.sent.
+package confirm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	logger "github.com/sirupsen/logrus"
+)
+
+type Outcome string
+
+const (
+	// Confirmed: the effect of the transaction is visible on chain
+	Confirmed Outcome = "confirmed"
+	// Rejected: the node refused the transaction
+	Rejected Outcome = "rejected"
+	// TimedOut: the effect of the transaction did not show up in time. The transaction was either
+	// not mined or the contract returned false, which the node does not report
+	TimedOut Outcome = "timed-out"
+)
+
+const (
+	defaultTimeout  = time.Minute * 2
+	defaultInterval = time.Second * 5
+)
+
+// Tx is a sent transaction.
+type Tx struct {
+	Kind string
+	// Fingerprint is a short hash of the transaction data. It is not the transaction ID, which
+	// RpcSend does not return, and two sends of the same data share it
+	Fingerprint string
+	SentAt      time.Time
+}
+
+// Config is the JSON form of the settings of Wait. Zero values fall back to the defaults.
+type Config struct {
+	TimeoutMs  int64
+	IntervalMs int64
+}
+
+// Check reports whether the effect of a transaction is visible on chain.
+type Check func(ctx context.Context) (bool, error)
+
+// NewTx records that a transaction of kind carrying data has been sent. Call it only once
+// RpcSend succeeded. RpcSend does not return the transaction ID, so the transaction is
+// logged with the fingerprint of its data.
+func NewTx(kind string, data string) Tx {
+	tx := newTx(kind, data)
+	metrics.GetOrRegisterCounter("tx."+kind+".sent", nil).Inc(1)
+	logger.WithFields(logger.Fields{
+		"kind":    tx.Kind,
+		"payload": tx.Fingerprint,
+	}).Info("Transaction is sent. Waiting for confirmation...")
+	return tx
+}
+
+// RecordRejected records a transaction of kind carrying data that the node refused to send.
+// It is not counted as sent.
+func RecordRejected(kind string, data string, reason string) {
+	Record(newTx(kind, data), Rejected, reason)
+}
+
+func newTx(kind string, data string) Tx {
+	hash := sha256.Sum256([]byte(data))
+	return Tx{Kind: kind, Fingerprint: hex.EncodeToString(hash[:8]), SentAt: time.Now()}
+}
+
+// Record writes the outcome of tx to the log and the metrics.
+func Record(tx Tx, outcome Outcome, reason string) {
+	metrics.GetOrRegisterCounter("tx."+tx.Kind+"."+string(outcome), nil).Inc(1)
+	entry := logger.WithFields(logger.Fields{
+		"kind":    tx.Kind,
+		"payload": tx.Fingerprint,
+		"outcome": outcome,
+		"elapsed": time.Since(tx.SentAt),
+	})
+	if outcome == Confirmed {
+		metrics.GetOrRegisterTimer("tx."+tx.Kind+".confirmation", nil).UpdateSince(tx.SentAt)
+		entry.Info("Transaction is confirmed")
+		return
+	}
+	entry.Warn("Transaction is not confirmed. Reason: ", reason)
+}
+
+// Wait runs check every interval until it reports the transaction as confirmed or the
+// timeout passes, and records the outcome.
+func Wait(ctx context.Context, tx Tx, check Check, config Config) Outcome {
+	timeout, interval := defaultTimeout, defaultInterval
+	if config.TimeoutMs > 0 {
+		timeout = time.Duration(config.TimeoutMs) * time.Millisecond
+	}
+	if config.IntervalMs > 0 {
+		interval = time.Duration(config.IntervalMs) * time.Millisecond
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reason := "not included within " + timeout.String()
+	for {
+		select {
+		case <-ticker.C:
+			confirmed, err := check(ctx)
+			if err != nil {
+				logger.WithFields(logger.Fields{
+					"kind":    tx.Kind,
+					"payload": tx.Fingerprint,
+				}).Debug("Confirmation check failed. Error:", err)
+				continue
+			}
+			if confirmed {
+				Record(tx, Confirmed, "")
+				return Confirmed
+			}
+		case <-deadline.C:
+			Record(tx, TimedOut, reason)
+			return TimedOut
+		case <-ctx.Done():
+			Record(tx, TimedOut, ctx.Err().Error())
+			return TimedOut
+		}
+	}
+}
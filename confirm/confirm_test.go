@@ -0,0 +1,61 @@
+package confirm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+var fast = Config{TimeoutMs: 200, IntervalMs: 1}
+
+func count(name string) int64 {
+	counter, ok := metrics.DefaultRegistry.Get(name).(metrics.Counter)
+	if !ok {
+		return 0
+	}
+	return counter.Count()
+}
+
+func TestWait_Confirmed(t *testing.T) {
+	tx := NewTx("test-confirmed", "data")
+	checks := 0
+	outcome := Wait(context.Background(), tx, func(ctx context.Context) (bool, error) {
+		checks++
+		if checks == 1 {
+			return false, errors.New("unavailable")
+		}
+		return checks == 3, nil
+	}, fast)
+
+	assert.Equal(t, Confirmed, outcome)
+	assert.Equal(t, 3, checks)
+	assert.Equal(t, int64(1), count("tx.test-confirmed.sent"))
+	assert.Equal(t, int64(1), count("tx.test-confirmed.confirmed"))
+	assert.Equal(t, int64(1), metrics.DefaultRegistry.Get("tx.test-confirmed.confirmation").(metrics.Timer).Count())
+}
+
+func TestWait_TimedOut(t *testing.T) {
+	tx := NewTx("test-timeout", "data")
+	outcome := Wait(context.Background(), tx, func(ctx context.Context) (bool, error) {
+		return false, nil
+	}, fast)
+
+	assert.Equal(t, TimedOut, outcome)
+	assert.Equal(t, int64(1), count("tx.test-timeout.timed-out"))
+	assert.Equal(t, int64(0), count("tx.test-timeout.confirmed"))
+}
+
+func TestNewTx(t *testing.T) {
+	assert.Equal(t, NewTx("test-id", "data").Fingerprint, NewTx("test-id", "data").Fingerprint)
+	assert.NotEqual(t, NewTx("test-id", "data").Fingerprint, NewTx("test-id", "other").Fingerprint)
+
+}
+
+func TestRecordRejected(t *testing.T) {
+	RecordRejected("test-rejected", "data", "insufficient balance")
+	assert.Equal(t, int64(1), count("tx.test-rejected.rejected"))
+	assert.Equal(t, int64(0), count("tx.test-rejected.sent"))
+}
@@ -0,0 +1,63 @@
+// Package contract embeds the IotSecurity smart contract, iot_security.js, into the programs,
+// so that deploy sends the audited source and the monitor can check that the contract it
+// registers with runs that source.
+//
+// A deployed contract is identified by the SHA-256 of its source. Releases lists the sources
+// this version of the monitor works with. Once a source is released, bump contractVersion in it with every change,
+// and add the previous release to Releases if the monitor still works with it.
+package contract
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"regexp"
+)
+
+//go:embed iot_security.js
+var Source string
+
+// Release is a released version of the contract source
+type Release struct {
+	Version string
+	// Sha256 is the SHA-256 of the released source
+	Sha256 string
+}
+
+// Current is the release of the embedded source
+var Current = Release{Version: versionOf(Source), Sha256: Sha256(Source)}
+
+// Releases are the contract releases the monitor can register with, the current one first.
+// 1.0.0 is the source the first fleets were deployed with, which has no contractVersion. It verifies
+// the registered info as it is sent, which is the canonical JSON the monitor signs.
+var Releases = []Release{
+	Current,
+	{Version: "1.0.0", Sha256: "0b825eb22718bee148972832fc8ff392bdc19df273959896fe1dcdc4204b3faf"},
+}
+
+// Sha256 returns the hex encoded SHA-256 of a contract source
+func Sha256(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// Identify returns the release of a deployed contract source, or false if it is not a known release.
+func Identify(source string) (Release, bool) {
+	hash := Sha256(source)
+	for _, release := range Releases {
+		if release.Sha256 == hash {
+			return release, true
+		}
+	}
+	return Release{Version: versionOf(source), Sha256: hash}, false
+}
+
+var versionPattern = regexp.MustCompile(`const\s+contractVersion\s*=\s*["']([^"']*)["']`)
+
+// versionOf reads the contractVersion constant of a source. It returns "" if there is none.
+func versionOf(source string) string {
+	if m := versionPattern.FindStringSubmatch(source); m != nil {
+		return m[1]
+	}
+	return ""
+}
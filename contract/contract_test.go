@@ -1,12 +1,15 @@
-package main_test
+package contract_test
 import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/dappley/go-dappley/core"
+	"github.com/dappley/iot-security/canonical"
+	"github.com/dappley/iot-security/contract"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"os/exec"
 	"strings"
 	"testing"
 
@@ -14,6 +17,9 @@ import (
 	"github.com/dappley/go-dappley/crypto/keystore/secp256k1"
 )
 
+//the address of the admin key of the tests, which the first setup makes the admin
+const adminAddr = "dHqWD1QtVqe9ioFWNUCQC2EAi6QZ9sg8Np"
+
 type InfoStruct struct{
 	Data 		string
 	BlkHeight 	uint64 `json:",string"`
@@ -48,7 +54,7 @@ func TestIotSecurity(t *testing.T) {
 	}
 	addrs := strings.Join(addrArray, ",")
 
-	data := sha256.Sum256([]byte(addrsContent))
+	data := sha256.Sum256([]byte("setup:0:" + addrsContent))
 	privData, err := hex.DecodeString(adminPrivKey)
 	assert.Nil(t, err)
 	signature, err := secp256k1.Sign(data[:], privData)
@@ -58,7 +64,7 @@ func TestIotSecurity(t *testing.T) {
 	assert.Equal(t,
 		"true",
 		sc.Execute("setup",
-			fmt.Sprintf("[%s],\"%s\",\"%s\"", addrs, adminPubKey, sig)),
+			fmt.Sprintf("[%s],\"%s\",\"%s\",\"%s\"", addrs, adminPubKey, sig, adminAddr)),
 	)
 
 	nodePubKey1 := "fd2681827b0e3be73d21e3238b155fce269d7c356f2b85a74a6b0bf6514cbd345dc848a7dad99d7d61dbd8a5e08ac0b21a52b8fc575e29af6f9e089b1bbb7c82"
@@ -66,7 +72,7 @@ func TestIotSecurity(t *testing.T) {
 	nodeAddr1 := "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
 
 	info := InfoStruct{"hello world", 2}
-	infoBytes, err := json.Marshal(info)
+	infoBytes, err := canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -89,7 +95,7 @@ func TestIotSecurity(t *testing.T) {
 
 	//now it should succeed after the block height is updated
 	info.BlkHeight = 3
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -117,7 +123,7 @@ func TestIotSecurity(t *testing.T) {
 
 	//node 1 register for blk height 4.
 	info.BlkHeight = 4
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -138,7 +144,7 @@ func TestIotSecurity(t *testing.T) {
 	//go to next block and get next batch
 	sc.ImportCurrBlockHeight(5)
 	info.BlkHeight = 5
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -273,7 +279,7 @@ func TestIotSecurity2(t *testing.T) {
 	}
 	addrs := strings.Join(addrArray, ",")
 
-	data := sha256.Sum256([]byte(addrsContent))
+	data := sha256.Sum256([]byte("setup:0:" + addrsContent))
 	privData, err := hex.DecodeString(adminPrivKey)
 	assert.Nil(t, err)
 	signature, err := secp256k1.Sign(data[:], privData)
@@ -283,7 +289,7 @@ func TestIotSecurity2(t *testing.T) {
 	assert.Equal(t,
 		"true",
 		sc.Execute("setup",
-			fmt.Sprintf("[%s],\"%s\",\"%s\"", addrs, adminPubKey, sig)),
+			fmt.Sprintf("[%s],\"%s\",\"%s\",\"%s\"", addrs, adminPubKey, sig, adminAddr)),
 	)
 
 	nodePubKey1 := "fd2681827b0e3be73d21e3238b155fce269d7c356f2b85a74a6b0bf6514cbd345dc848a7dad99d7d61dbd8a5e08ac0b21a52b8fc575e29af6f9e089b1bbb7c82"
@@ -291,7 +297,7 @@ func TestIotSecurity2(t *testing.T) {
 	nodeAddr1 := "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
 
 	info := InfoStruct{"hello world", 2}
-	infoBytes, err := json.Marshal(info)
+	infoBytes, err := canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -314,7 +320,7 @@ func TestIotSecurity2(t *testing.T) {
 
 	//the block height is correct, but its not the time to register yet. needs to wait until height 5
 	info.BlkHeight = 3
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -352,7 +358,7 @@ func TestIotSecurity2(t *testing.T) {
 	sc.ImportCurrBlockHeight(5)
 	//the block height is correct, but its not the time to register yet. needs to wait until height 5
 	info.BlkHeight = 4
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -375,7 +381,7 @@ func TestIotSecurity2(t *testing.T) {
 	sc.ImportCurrBlockHeight(6)
 	//the block height is correct, but its not the time to register yet. needs to wait until height 5
 	info.BlkHeight = 5
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -398,7 +404,7 @@ func TestIotSecurity2(t *testing.T) {
 	sc.ImportCurrBlockHeight(7)
 	//the block height is correct, but its not the time to register yet. needs to wait until height 5
 	info.BlkHeight = 6
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -421,7 +427,7 @@ func TestIotSecurity2(t *testing.T) {
 	sc.ImportCurrBlockHeight(8)
 	//the block height is correct, but its not the time to register yet. needs to wait until height 5
 	info.BlkHeight = 7
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -444,7 +450,7 @@ func TestIotSecurity2(t *testing.T) {
 	sc.ImportCurrBlockHeight(9)
 	//the block height is correct, but its not the time to register yet. needs to wait until height 5
 	info.BlkHeight = 8
-	infoBytes, err = json.Marshal(info)
+	infoBytes, err = canonical.Marshal(info)
 	assert.Nil(t, err)
 	sig, err = signData(infoBytes, nodePrivateKey1)
 	assert.Nil(t, err)
@@ -461,4 +467,222 @@ func TestIotSecurity2(t *testing.T) {
 		sc.Execute("dapp_schedule",
 			fmt.Sprintf("\"%s\"", nodeAddr1)),
 	)
-}
\ No newline at end of file
+}
+//TestIotSecurity_canonicalVectors checks the contract against the vectors of the canonical go package,
+//so that every info signed by the monitor is verified by the contract
+func TestIotSecurity_canonicalVectors(t *testing.T) {
+	raw, err := ioutil.ReadFile("../../iot-security/canonical/testdata/vectors.json")
+	assert.Nil(t, err)
+	var vectors struct {
+		Canonical []struct {
+			Name      string
+			Input     json.RawMessage
+			Canonical string
+		}
+		Signatures []struct {
+			Name      string
+			Info      json.RawMessage
+			Canonical string
+			Addr      string
+			PubKey    string
+			Sig       string
+		}
+	}
+	assert.Nil(t, json.Unmarshal(raw, &vectors))
+
+	script, _ := ioutil.ReadFile("../../iot-security/contract/iot_security.js")
+	sc := NewV8Engine()
+	sc.ImportSourceCode(string(script))
+	sc.ImportLocalStorage(make(map[string]string))
+
+	for _, v := range vectors.Canonical {
+		assert.Equal(t, v.Canonical, sc.Execute("canonicalize", string(v.Input)), v.Name)
+	}
+	for _, v := range vectors.Signatures {
+		assert.Equal(t, v.Canonical, sc.Execute("canonicalize", string(v.Info)), v.Name)
+		assert.Equal(t,
+			"true",
+			sc.Execute("verifyInfo",
+				fmt.Sprintf("%s,\"%s\",\"%s\",\"%s\"", string(v.Info), v.Addr, v.PubKey, v.Sig)),
+			v.Name,
+		)
+	}
+}
+
+//TestIotSecurity_membership adds, removes and replaces nodes after the setup
+func TestIotSecurity_membership(t *testing.T) {
+	script, _ := ioutil.ReadFile("../../iot-security/contract/iot_security.js")
+	sc := NewV8Engine()
+	ss := make(map[string]string)
+	sc.ImportSourceCode(string(script))
+	sc.ImportLocalStorage(ss)
+	sc.ImportCurrBlockHeight(2)
+	sc.ImportSeed(130)
+
+	adminPubKey := "7c74f836ddeba3f813c5c298d7f67d65da012b04c51f2e13bad6a734696a692f1db40731630310910c69163695e959b0f61f4caf05626583af8a4a1bd41096aa"
+	adminPrivKey := "21e4861b11bd646aa7c5807af8285c57bc8bec82b690c5ceaea482afb4da4589"
+	nodePrivateKey1 := "f22bac4a73a9881d523075d9bb749ca537c7fa451366d935bcb65509968ac3e4"
+	addr1 := "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
+	addr2 := "dRE5XUM2demeG8unwsWgs1WRGSUGdgWaDo"
+	addr3 := "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A"
+
+	//an address can not be listed twice
+	sig, err := signData([]byte("setup:0:"+addr1+","+addr1), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("setup", fmt.Sprintf("[\"%s\",\"%s\"],\"%s\",\"%s\",\"%s\"", addr1, addr1, adminPubKey, sig, adminAddr)))
+
+	sig, err = signData([]byte("setup:0:"+addr1+","+addr2), adminPrivKey)
+	assert.Nil(t, err)
+	setup := fmt.Sprintf("[\"%s\",\"%s\"],\"%s\",\"%s\",\"%s\"", addr1, addr2, adminPubKey, sig, adminAddr)
+	assert.Equal(t, "true", sc.Execute("setup", setup))
+	assert.Equal(t, "1", ss["membershipNonce"])
+	//the signed setup can not be sent again
+	assert.Equal(t, "false", sc.Execute("setup", setup))
+
+	//the change must be signed by the admin with the current nonce
+	sig, err = signData([]byte("addNodes:1:"+addr3), nodePrivateKey1)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("addNodes", fmt.Sprintf("[\"%s\"],\"%s\",\"%s\"", addr3, adminPubKey, sig)))
+	sig, err = signData([]byte("addNodes:0:"+addr3), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("addNodes", fmt.Sprintf("[\"%s\"],\"%s\",\"%s\"", addr3, adminPubKey, sig)))
+
+	sig, err = signData([]byte("addNodes:1:"+addr3+","+addr3), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("addNodes", fmt.Sprintf("[\"%s\",\"%s\"],\"%s\",\"%s\"", addr3, addr3, adminPubKey, sig)))
+
+	//the batches are randomized again from the new block height
+	sc.ImportCurrBlockHeight(5)
+	sig, err = signData([]byte("addNodes:1:"+addr3), adminPrivKey)
+	assert.Nil(t, err)
+	addNode3 := fmt.Sprintf("[\"%s\"],\"%s\",\"%s\"", addr3, adminPubKey, sig)
+	assert.Equal(t, "true", sc.Execute("addNodes", addNode3))
+	assert.Equal(t, addr1+","+addr2+","+addr3, ss["allNodeAddresses"])
+	assert.Equal(t, "5", ss["targetStartingBlkHeight"])
+	assert.Equal(t, "5", ss["verifierStartingBlkHeight"])
+	assert.Contains(t, ss["targetAddresses"], addr3)
+	//the signed change can not be sent again
+	assert.Equal(t, "false", sc.Execute("addNodes", addNode3))
+
+	sig, err = signData([]byte("replaceNode:2:"+addr2+","+addr3), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("replaceNode", fmt.Sprintf("\"%s\",\"%s\",\"%s\",\"%s\"", addr2, addr3, adminPubKey, sig)))
+
+	sig, err = signData([]byte("removeNodes:2:"+addr2), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", sc.Execute("removeNodes", fmt.Sprintf("[\"%s\"],\"%s\",\"%s\"", addr2, adminPubKey, sig)))
+	assert.Equal(t, addr1+","+addr3, ss["allNodeAddresses"])
+	assert.NotContains(t, ss["targetAddresses"], addr2)
+
+	sig, err = signData([]byte("replaceNode:3:"+addr3+","+addr2), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", sc.Execute("replaceNode", fmt.Sprintf("\"%s\",\"%s\",\"%s\",\"%s\"", addr3, addr2, adminPubKey, sig)))
+	assert.Equal(t, addr1+","+addr2, ss["allNodeAddresses"])
+
+	//the last nodes can not be removed
+	sig, err = signData([]byte("removeNodes:4:"+addr1+","+addr2), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("removeNodes", fmt.Sprintf("[\"%s\",\"%s\"],\"%s\",\"%s\"", addr1, addr2, adminPubKey, sig)))
+}
+
+//TestIotSecurity_rotateAdmin hands the admin role over to the key of node 1
+func TestIotSecurity_rotateAdmin(t *testing.T) {
+	script, _ := ioutil.ReadFile("../../iot-security/contract/iot_security.js")
+	sc := NewV8Engine()
+	ss := make(map[string]string)
+	sc.ImportSourceCode(string(script))
+	sc.ImportLocalStorage(ss)
+	sc.ImportCurrBlockHeight(2)
+	sc.ImportSeed(130)
+
+	adminPubKey := "7c74f836ddeba3f813c5c298d7f67d65da012b04c51f2e13bad6a734696a692f1db40731630310910c69163695e959b0f61f4caf05626583af8a4a1bd41096aa"
+	adminPrivKey := "21e4861b11bd646aa7c5807af8285c57bc8bec82b690c5ceaea482afb4da4589"
+	nodePubKey1 := "fd2681827b0e3be73d21e3238b155fce269d7c356f2b85a74a6b0bf6514cbd345dc848a7dad99d7d61dbd8a5e08ac0b21a52b8fc575e29af6f9e089b1bbb7c82"
+	nodePrivateKey1 := "f22bac4a73a9881d523075d9bb749ca537c7fa451366d935bcb65509968ac3e4"
+	addr1 := "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
+	addr2 := "dRE5XUM2demeG8unwsWgs1WRGSUGdgWaDo"
+
+	//the first setup must be signed by the key of the admin it names
+	sig, err := signData([]byte("setup:0:"+addr1), nodePrivateKey1)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("setup", fmt.Sprintf("[\"%s\"],\"%s\",\"%s\",\"%s\"", addr1, nodePubKey1, sig, adminAddr)))
+	assert.Equal(t, "", ss["adminAddress"])
+	sig, err = signData([]byte("setup:0:"+addr1), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", sc.Execute("setup", fmt.Sprintf("[\"%s\"],\"%s\",\"%s\",\"%s\"", addr1, adminPubKey, sig, adminAddr)))
+	assert.Equal(t, adminAddr, ss["adminAddress"])
+
+	sig, err = signData([]byte("rotateAdmin:0:"+addr1), adminPrivKey)
+	assert.Nil(t, err)
+	rotate := fmt.Sprintf("\"%s\",\"%s\",\"%s\"", addr1, adminPubKey, sig)
+
+	//only the admin can rotate
+	sig, err = signData([]byte("rotateAdmin:0:"+addr1), nodePrivateKey1)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("rotateAdmin", fmt.Sprintf("\"%s\",\"%s\",\"%s\"", addr1, nodePubKey1, sig)))
+
+	assert.Equal(t, "true", sc.Execute("rotateAdmin", rotate))
+	assert.Equal(t, addr1, ss["adminAddress"])
+	assert.Equal(t, "1", ss["adminNonce"])
+	//the signed rotation can not be sent again
+	assert.Equal(t, "false", sc.Execute("rotateAdmin", rotate))
+
+	//the old admin key is refused, the new one is accepted
+	sig, err = signData([]byte("setup:1:"+addr1+","+addr2), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("setup", fmt.Sprintf("[\"%s\",\"%s\"],\"%s\",\"%s\",\"%s\"", addr1, addr2, adminPubKey, sig, adminAddr)))
+	sig, err = signData([]byte("addNodes:1:"+addr2), nodePrivateKey1)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", sc.Execute("addNodes", fmt.Sprintf("[\"%s\"],\"%s\",\"%s\"", addr2, nodePubKey1, sig)))
+	assert.Equal(t, addr1+","+addr2, ss["allNodeAddresses"])
+}
+
+//TestIotSecurity_beforeSetup checks that a contract has no admin until its first setup names one
+func TestIotSecurity_beforeSetup(t *testing.T) {
+	script, _ := ioutil.ReadFile("../../iot-security/contract/iot_security.js")
+	sc := NewV8Engine()
+	ss := make(map[string]string)
+	sc.ImportSourceCode(string(script))
+	sc.ImportLocalStorage(ss)
+	sc.ImportCurrBlockHeight(2)
+	sc.ImportSeed(130)
+
+	adminPubKey := "7c74f836ddeba3f813c5c298d7f67d65da012b04c51f2e13bad6a734696a692f1db40731630310910c69163695e959b0f61f4caf05626583af8a4a1bd41096aa"
+	adminPrivKey := "21e4861b11bd646aa7c5807af8285c57bc8bec82b690c5ceaea482afb4da4589"
+	addr1 := "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
+
+	//there is no admin to rotate yet, like iotsec admin rotate refuses
+	sig, err := signData([]byte("rotateAdmin:0:"+addr1), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("rotateAdmin", fmt.Sprintf("\"%s\",\"%s\",\"%s\"", addr1, adminPubKey, sig)))
+	assert.Equal(t, "", ss["adminAddress"])
+
+	//a setup that names no admin is refused
+	sig, err = signData([]byte("setup:0:"+addr1), adminPrivKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", sc.Execute("setup", fmt.Sprintf("[\"%s\"],\"%s\",\"%s\"", addr1, adminPubKey, sig)))
+	assert.Equal(t, "", ss["allNodeAddresses"])
+}
+
+func TestIdentify(t *testing.T) {
+	release, known := contract.Identify(contract.Source)
+	assert.True(t, known)
+	assert.Equal(t, contract.Current, release)
+
+	_, known = contract.Identify(strings.Replace(contract.Source, "numOfVerifierBatch = 2", "numOfVerifierBatch = 1", 1))
+	assert.False(t, known)
+}
+
+//baselineCommit holds iot_security.js 1.0.0, the source the first fleets were deployed with
+const baselineCommit = "6163e30"
+
+func TestIdentify_baseline(t *testing.T) {
+	source, err := exec.Command("git", "show", baselineCommit+":contract/iot_security.js").Output()
+	if err != nil {
+		t.Skip("the git history is not available: ", err)
+	}
+	release, known := contract.Identify(string(source))
+	assert.True(t, known)
+	assert.Equal(t, "1.0.0", release.Version)
+	assert.Equal(t, contract.Releases[len(contract.Releases)-1], release)
+}
@@ -15,11 +15,21 @@ const keyVerifyTargetAddrs = "targetAddresses";
 const keyVerifierStartingBlkHeight = "verifierStartingBlkHeight";
 const keyVerifierAddrs = "verifierAddresses";
 
+//counts the membership changes. The admin signs it with every change, so that a signature can not be replayed
+const keyMembershipNonce = "membershipNonce";
+
+//the admin, who signs the setup, the membership changes and the rotation of the admin. Set by the first setup and the rotations
+const keyAdminAddr = "adminAddress";
+//counts the rotations of the admin, like membershipNonce
+const keyAdminNonce = "adminNonce";
+
+//version of this source. Change it with every change to the contract, see contract.go
+const contractVersion = "2.0.0";
+
 const InfoKeyHeight = "BlkHeight";
 const InfoKeyData = "Data";
 
 //should be configurable
-const adminAddr = "dHqWD1QtVqe9ioFWNUCQC2EAi6QZ9sg8Np";
 const numOfVerifyTargetBatch = 3;
 const numOfVerifierBatch = 2;
 
@@ -49,9 +59,8 @@ IotSecurity.prototype = {
             _log.debug("Register: Last possible upload block height:", newBatchBlockHeight);
             return false;
         }
-        let infoString = JSON.stringify(info);
         //verify publickey and signature
-        if (!this.verify(infoString, addr, pubKey, sig)){
+        if (!this.verifyInfo(info, addr, pubKey, sig)){
             _log.warn("Register: Verification failed");
             return false
         }
@@ -79,23 +88,170 @@ IotSecurity.prototype = {
         LocalStorage.set(addr, result);
         return true
     },
-    setup: function(addrs, pubKey, sig){
-        if (!crypto.verifyPublicKey(adminAddr, pubKey)){
+    //sets the verification list. The admin signs "setup:<membershipNonce>:<addr1,addr2,...>".
+    //The first setup makes newAdmin, the address of pubKey, the admin. Later setups must be signed by the stored admin
+    setup: function(addrs, pubKey, sig, newAdmin){
+        let admin = this.getAdmin();
+        if (!admin){
+            admin = newAdmin;
+        }
+        if (!admin){
+            _log.warn("Setup: No admin address");
             return false;
         }
-        if (!crypto.verifySignature(addrs.toString(), pubKey, sig)){
+        if (this.hasDuplicates("Setup", addrs)){
+            return false;
+        }
+        if (!this.verify("setup:" + this.getNonce(keyMembershipNonce) + ":" + addrs.toString(), admin, pubKey, sig)){
+            _log.warn("Setup: Verification failed");
             return false;
         }
 
+        if (!this.setNodeAddresses(addrs)){
+            return false;
+        }
+        LocalStorage.set(keyAdminAddr, admin);
+        return true;
+    },
+    //hands the admin role over to newAdmin, e.g. when the admin key has leaked. The current admin signs "rotateAdmin:<adminNonce>:<newAdmin>"
+    rotateAdmin: function(newAdmin, pubKey, sig){
+        let admin = this.getAdmin();
+        if (!admin){
+            _log.warn("RotateAdmin: The contract has no admin");
+            return false;
+        }
+        if (!newAdmin){
+            _log.warn("RotateAdmin: No new admin address");
+            return false;
+        }
+        let nonce = this.getNonce(keyAdminNonce);
+        if (!this.verify("rotateAdmin:" + nonce + ":" + newAdmin, admin, pubKey, sig)){
+            _log.warn("RotateAdmin: Verification failed");
+            return false;
+        }
+        LocalStorage.set(keyAdminAddr, newAdmin);
+        LocalStorage.set(keyAdminNonce, (nonce + 1).toString());
+        return true;
+    },
+    //the stored admin, or "" if the contract has not been set up yet
+    getAdmin: function(){
+        let admin = LocalStorage.get(keyAdminAddr);
+        if (!admin){
+            return "";
+        }
+        return admin;
+    },
+    //adds nodes to the verification list. The admin signs "addNodes:<membershipNonce>:<addr1,addr2,...>"
+    addNodes: function(addrs, pubKey, sig){
+        if (!this.verifyMembershipChange("addNodes", addrs, pubKey, sig)){
+            return false;
+        }
+        let nodes = this.getNodeAddresses();
+        let i;
+        for (i = 0; i < addrs.length; i++){
+            if (nodes.includes(addrs[i])){
+                _log.warn("AddNodes: Address is already in the verification list. Addr:", addrs[i]);
+                return false;
+            }
+        }
+        return this.setNodeAddresses(nodes.concat(addrs));
+    },
+    //removes nodes from the verification list. The admin signs "removeNodes:<membershipNonce>:<addr1,addr2,...>"
+    removeNodes: function(addrs, pubKey, sig){
+        if (!this.verifyMembershipChange("removeNodes", addrs, pubKey, sig)){
+            return false;
+        }
+        let nodes = this.getNodeAddresses();
+        let i;
+        for (i = 0; i < addrs.length; i++){
+            if (!nodes.includes(addrs[i])){
+                _log.warn("RemoveNodes: Address is not in the verification list. Addr:", addrs[i]);
+                return false;
+            }
+        }
+        let remaining = nodes.filter(function(addr){
+            return !addrs.includes(addr);
+        });
+        if (remaining.length == 0){
+            _log.warn("RemoveNodes: Not able to remove all nodes. Use setup instead");
+            return false;
+        }
+        return this.setNodeAddresses(remaining);
+    },
+    //replaces a node, e.g. a replaced device, keeping its place in the list. The admin signs "replaceNode:<membershipNonce>:<oldAddr>,<newAddr>"
+    replaceNode: function(oldAddr, newAddr, pubKey, sig){
+        if (!this.verifyMembershipChange("replaceNode", [oldAddr, newAddr], pubKey, sig)){
+            return false;
+        }
+        let nodes = this.getNodeAddresses();
+        let index = nodes.indexOf(oldAddr);
+        if (index == -1){
+            _log.warn("ReplaceNode: Address is not in the verification list. Addr:", oldAddr);
+            return false;
+        }
+        if (nodes.includes(newAddr)){
+            _log.warn("ReplaceNode: Address is already in the verification list. Addr:", newAddr);
+            return false;
+        }
+        nodes[index] = newAddr;
+        return this.setNodeAddresses(nodes);
+    },
+    verifyMembershipChange: function(functionName, addrs, pubKey, sig){
+        if (!Array.isArray(addrs) || addrs.length == 0){
+            _log.warn(functionName, ": No addresses");
+            return false;
+        }
+        if (this.hasDuplicates(functionName, addrs)){
+            return false;
+        }
+        if (!LocalStorage.get(keyAddrs)){
+            _log.warn(functionName, ": The contract has not been set up yet");
+            return false;
+        }
+        let msg = functionName + ":" + this.getNonce(keyMembershipNonce) + ":" + addrs.toString();
+        if (!this.verify(msg, this.getAdmin(), pubKey, sig)){
+            _log.warn(functionName, ": Verification failed");
+            return false;
+        }
+        return true;
+    },
+    //a node listed twice would be verified twice per cycle and could not be removed cleanly
+    hasDuplicates: function(functionName, addrs){
+        let i;
+        for (i = 0; i < addrs.length; i++){
+            if (addrs.indexOf(addrs[i]) != i){
+                _log.warn(functionName, ": Address is listed twice. Addr:", addrs[i]);
+                return true;
+            }
+        }
+        return false;
+    },
+    getNodeAddresses: function(){
+        let addrs = LocalStorage.get(keyAddrs);
+        if (!addrs){
+            return [];
+        }
+        return addrs.split(",");
+    },
+    //stores the verification list and re-randomizes the batches, so that the batches only hold the nodes of the list
+    setNodeAddresses: function(addrs){
         if (LocalStorage.set(keyAddrs, addrs.toString())===1){
             return false;
         }
+        LocalStorage.set(keyMembershipNonce, (this.getNonce(keyMembershipNonce) + 1).toString());
 
         this.setNextVerifierBatch();
         this.setNextVerifyTargetsBatch();
 
         return true;
     },
+    getNonce: function(nonceKey){
+        let nonce = LocalStorage.get(nonceKey);
+        if (!nonce){
+            return 0;
+        }
+        return parseInt(nonce, 10);
+    },
     dapp_schedule: function() {
         _log.debug("IoT Security: Verifying...");
         //get the verifier this round
@@ -231,6 +387,35 @@ IotSecurity.prototype = {
         }
         return true;
     },
+    //the info is signed in its canonical JSON form (RFC 8785), the same encoding as the canonical go package
+    verifyInfo: function(info, addr, pubKey, sig){
+        return this.verify(this.canonicalize(info), addr, pubKey, sig);
+    },
+    canonicalize: function(value){
+        if (value === null || typeof value !== "object"){
+            //JSON.stringify already writes strings, numbers and literals canonically
+            return JSON.stringify(value);
+        }
+        if (Array.isArray(value)){
+            let items = [];
+            let i;
+            for (i = 0; i < value.length; i++){
+                items.push(value[i] === undefined ? "null" : this.canonicalize(value[i]));
+            }
+            return "[" + items.join(",") + "]";
+        }
+        //the default sort compares UTF-16 code units as RFC 8785 requires
+        let names = Object.keys(value).sort();
+        let members = [];
+        let i;
+        for (i = 0; i < names.length; i++){
+            if (value[names[i]] === undefined){
+                continue;
+            }
+            members.push(JSON.stringify(names[i]) + ":" + this.canonicalize(value[names[i]]));
+        }
+        return "{" + members.join(",") + "}";
+    },
     check: function(addr){
         let data = LocalStorage.get(addr);
         if (!data){
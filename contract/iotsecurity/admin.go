@@ -0,0 +1,27 @@
+package iotsecurity
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/signer"
+)
+
+// RotateAdmin calls rotateAdmin, which makes newAdmin the admin of the contract. The contract verifies
+// the signature of the current admin over "rotateAdmin:<nonce>:<newAdmin>", where nonce is the admin
+// nonce of the contract, see Client.AdminNonce.
+func RotateAdmin(newAdmin string, nonce uint64, admin signer.Signer) (Call, error) {
+	if newAdmin == "" {
+		return Call{}, errs.Config("check new admin", errors.New("no address"))
+	}
+	sig, err := sign(admin, []byte(fmt.Sprintf("%s:%d:%s", FunctionRotateAdmin, nonce, newAdmin)))
+	if err != nil {
+		return Call{}, errs.Key("sign new admin", err)
+	}
+	return Call{
+		Function: FunctionRotateAdmin,
+		Args:     []string{newAdmin, hex.EncodeToString(admin.PublicKey()), sig},
+	}, nil
+}
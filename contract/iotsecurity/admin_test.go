@@ -0,0 +1,21 @@
+package iotsecurity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateAdmin(t *testing.T) {
+	call, err := RotateAdmin(node2Addr, 4, node1Signer(t))
+	assert.Nil(t, err)
+	assert.Equal(t, FunctionRotateAdmin, call.Function)
+	if assert.Len(t, call.Args, 3) {
+		assert.Equal(t, node2Addr, call.Args[0])
+		assert.Equal(t, node1PubKey, call.Args[1])
+		assertSignedBy(t, "rotateAdmin:4:"+node2Addr, call.Args[2])
+	}
+
+	_, err = RotateAdmin("", 4, node1Signer(t))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,293 @@
+package iotsecurity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dappley/go-dappley/client"
+	"github.com/dappley/go-dappley/common"
+	"github.com/dappley/go-dappley/rpc/pb"
+	"github.com/dappley/iot-security/budget"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/rpcclient"
+	"github.com/dappley/iot-security/schedule"
+	"github.com/dappley/iot-security/signer"
+	"google.golang.org/grpc"
+)
+
+// storage keys of the contract, see iot_security.js
+const (
+	keyAddrs                         = "allNodeAddresses"
+	keyVerifyTargetStartingBlkHeight = "targetStartingBlkHeight"
+	keyVerifyTargetAddrs             = "targetAddresses"
+	keyVerifierStartingBlkHeight     = "verifierStartingBlkHeight"
+	keyVerifierAddrs                 = "verifierAddresses"
+	keyMembershipNonce               = "membershipNonce"
+	keyAdminAddr                     = "adminAddress"
+	keyAdminNonce                    = "adminNonce"
+)
+
+// numbers of batches the contract splits the nodes into, numOfVerifyTargetBatch and numOfVerifierBatch in iot_security.js
+const (
+	NumOfVerifyTargetBatches = 3
+	NumOfVerifierBatches     = 2
+)
+
+// Sender pays for the calls sent to the contract from its wallet
+type Sender struct {
+	From string
+	Fee  budget.Fee
+}
+
+// Conns provides the connection to a node, and fails over to another node after a failed call, like rpcclient.Pool
+type Conns interface {
+	Conn() *grpc.ClientConn
+	ReportFailure()
+}
+
+// Client calls the contract at an address through a node connection
+type Client struct {
+	conns        Conns
+	contractAddr string
+}
+
+// NewClient creates a client that always calls through conn
+func NewClient(conn *grpc.ClientConn, contractAddr string) *Client {
+	return &Client{conns: fixedConn{conn}, contractAddr: contractAddr}
+}
+
+// NewPoolClient creates a client that calls through the current connection of conns, and reports
+// transport errors to it, so that the next call, e.g. a retry, goes to another node
+func NewPoolClient(conns Conns, contractAddr string) *Client {
+	return &Client{conns: conns, contractAddr: contractAddr}
+}
+
+type fixedConn struct {
+	conn *grpc.ClientConn
+}
+
+func (f fixedConn) Conn() *grpc.ClientConn { return f.conn }
+
+func (f fixedConn) ReportFailure() {}
+
+// report reports a transport error to the connections and returns err
+func (c *Client) report(err error) error {
+	if errs.KindOf(err) == errs.KindTransport {
+		c.conns.ReportFailure()
+	}
+	return err
+}
+
+// Send sends the call as a transaction to the contract. It returns once the node has accepted the
+// transaction, which has yet to be mined, see the confirm package.
+func (c *Client) Send(ctx context.Context, call Call, from Sender) error {
+	data, err := call.Data()
+	if err != nil {
+		return errs.Config("encode "+call.Function, err)
+	}
+	_, err = rpcpb.NewAdminServiceClient(c.conns.Conn()).RpcSend(ctx, &rpcpb.SendRequest{
+		From:       from.From,
+		To:         c.contractAddr,
+		Amount:     common.NewAmount(from.Fee.Amount).Bytes(),
+		Tip:        common.NewAmount(from.Fee.Tip).Bytes(),
+		WalletPath: client.GetWalletFilePath(),
+		Data:       data,
+	})
+	if err != nil {
+		return c.report(errs.RPC("send "+call.Function, err))
+	}
+	return nil
+}
+
+// Register sends the signed info of a node
+func (c *Client) Register(ctx context.Context, info Info, node signer.Signer, from Sender) error {
+	call, err := Register(info, node)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, call, from)
+}
+
+// Setup sends the node addresses signed by the admin with the current membership nonce
+func (c *Client) Setup(ctx context.Context, addrs []string, admin signer.Signer, from Sender) error {
+	nonce, err := c.MembershipNonce(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := Setup(addrs, nonce, admin)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, call, from)
+}
+
+// AddNodes sends the addresses to add, signed by the admin with the current membership nonce
+func (c *Client) AddNodes(ctx context.Context, addrs []string, admin signer.Signer, from Sender) error {
+	nonce, err := c.MembershipNonce(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := AddNodes(addrs, nonce, admin)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, call, from)
+}
+
+// RemoveNodes sends the addresses to remove, signed by the admin with the current membership nonce
+func (c *Client) RemoveNodes(ctx context.Context, addrs []string, admin signer.Signer, from Sender) error {
+	nonce, err := c.MembershipNonce(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := RemoveNodes(addrs, nonce, admin)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, call, from)
+}
+
+// ReplaceNode sends the replacement of a node, signed by the admin with the current membership nonce
+func (c *Client) ReplaceNode(ctx context.Context, oldAddr string, newAddr string, admin signer.Signer, from Sender) error {
+	nonce, err := c.MembershipNonce(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := ReplaceNode(oldAddr, newAddr, nonce, admin)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, call, from)
+}
+
+// RotateAdmin sends the new admin address, signed by the current admin with the current admin nonce
+func (c *Client) RotateAdmin(ctx context.Context, newAdmin string, admin signer.Signer, from Sender) error {
+	nonce, err := c.AdminNonce(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := RotateAdmin(newAdmin, nonce, admin)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, call, from)
+}
+
+// DappSchedule sends a call of dapp_schedule
+func (c *Client) DappSchedule(ctx context.Context, from Sender) error {
+	return c.Send(ctx, DappSchedule(), from)
+}
+
+// Query reads a key of the contract storage. A missing key reads as an empty value.
+func (c *Client) Query(ctx context.Context, key string) (string, error) {
+	value, err := rpcclient.ContractQuery(c.conns.Conn(), c.contractAddr)(ctx, key)
+	return value, c.report(err)
+}
+
+// NodeAddresses returns the addresses of the nodes set up in the contract
+func (c *Client) NodeAddresses(ctx context.Context) ([]string, error) {
+	value, err := c.Query(ctx, keyAddrs)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	return strings.Split(value, ","), nil
+}
+
+// MembershipNonce returns the number of changes of the node addresses, which the admin signs with the next change
+func (c *Client) MembershipNonce(ctx context.Context) (uint64, error) {
+	return c.nonce(ctx, keyMembershipNonce)
+}
+
+// Admin returns the address of the admin of the contract. It is empty before the first setup, and for
+// contracts before 2.0.0, whose admin is fixed in their source.
+func (c *Client) Admin(ctx context.Context) (string, error) {
+	return c.Query(ctx, keyAdminAddr)
+}
+
+// AdminNonce returns the number of rotations of the admin, which the admin signs with the next rotation
+func (c *Client) AdminNonce(ctx context.Context) (uint64, error) {
+	return c.nonce(ctx, keyAdminNonce)
+}
+
+func (c *Client) nonce(ctx context.Context, key string) (uint64, error) {
+	value, err := c.Query(ctx, key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	nonce, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return nonce, nil
+}
+
+// Check evaluates the contract's check of addr. It returns "" if the node passes, or the reason it fails.
+func (c *Client) Check(ctx context.Context, addr string) (string, error) {
+	return schedule.Check(ctx, c.Query, addr)
+}
+
+// Batch is a batch of nodes of the contract
+type Batch struct {
+	// Index of the batch, or -1 if the contract has no batch for the next block
+	Index int
+	// StartingBlkHeight is the block height the batches were set at
+	StartingBlkHeight uint64
+	Addresses         []string
+}
+
+// GetNextVerifierBatch returns the verifier batch the contract uses on the next block, whose
+// nodes verify the targets of GetNextVerifyTargetBatch
+func (c *Client) GetNextVerifierBatch(ctx context.Context) (Batch, error) {
+	return c.nextBatch(ctx, keyVerifierAddrs, keyVerifierStartingBlkHeight, NumOfVerifierBatches)
+}
+
+// GetNextVerifyTargetBatch returns the target batch the contract checks on the next block
+func (c *Client) GetNextVerifyTargetBatch(ctx context.Context) (Batch, error) {
+	return c.nextBatch(ctx, keyVerifyTargetAddrs, keyVerifyTargetStartingBlkHeight, NumOfVerifyTargetBatches)
+}
+
+func (c *Client) nextBatch(ctx context.Context, addrsKey string, startingBlkHeightKey string, numOfBatches int) (Batch, error) {
+	resp, err := rpcpb.NewRpcServiceClient(c.conns.Conn()).RpcGetBlockchainInfo(ctx, &rpcpb.GetBlockchainInfoRequest{})
+	if err != nil {
+		return Batch{Index: -1}, c.report(errs.RPC("get block height", err))
+	}
+	return readBatch(ctx, c.Query, resp.BlockHeight+1, addrsKey, startingBlkHeightKey, numOfBatches)
+}
+
+// readBatch reads the batch the contract uses at blkHeight, like getNextBatchIndex and getBatchByIndex do
+func readBatch(ctx context.Context, query schedule.QueryFunc, blkHeight uint64, addrsKey string, startingBlkHeightKey string, numOfBatches int) (Batch, error) {
+	batch := Batch{Index: -1}
+	value, err := query(ctx, startingBlkHeightKey)
+	if err != nil {
+		return batch, err
+	}
+	if value != "" {
+		if batch.StartingBlkHeight, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return batch, fmt.Errorf("invalid %s %q", startingBlkHeightKey, value)
+		}
+	}
+	//batch i is used at block start+i+1
+	if batch.StartingBlkHeight == 0 || blkHeight <= batch.StartingBlkHeight || blkHeight-batch.StartingBlkHeight-1 >= uint64(numOfBatches) {
+		return batch, nil
+	}
+	index := int(blkHeight - batch.StartingBlkHeight - 1)
+
+	value, err = query(ctx, addrsKey)
+	if err != nil {
+		return batch, err
+	}
+	batches := map[string]string{}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &batches); err != nil {
+			return batch, fmt.Errorf("invalid %s: %v", addrsKey, err)
+		}
+	}
+	batch.Index = index
+	if addrs := batches[strconv.Itoa(index)]; addrs != "" {
+		batch.Addresses = strings.Split(addrs, ",")
+	}
+	return batch, nil
+}
@@ -0,0 +1,130 @@
+// Package iotsecurity is the Go client of the IotSecurity contract, iot_security.js.
+//
+// A contract function is called by sending a transaction whose data is the function name
+// and its arguments, e.g. {"function":"setup","args":["[\"dGGG...\"]","7c74...","3045..."]}.
+// The node passes an argument that is JSON, such as the list of setup, as the JSON value,
+// and any other argument as a string. Call builds that data from typed arguments, and signs
+// what the contract verifies, so that callers do not format the arguments themselves.
+//
+// A transaction does not return the result of the function. The functions that only read
+// the contract, such as Check, are evaluated by Client from the contract storage, the way
+// the contract evaluates them.
+package iotsecurity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dappley/iot-security/canonical"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/signer"
+)
+
+// names of the contract functions
+const (
+	FunctionRegister     = "register"
+	FunctionSetup        = "setup"
+	FunctionDappSchedule = "dapp_schedule"
+	FunctionAddNodes     = "addNodes"
+	FunctionRemoveNodes  = "removeNodes"
+	FunctionReplaceNode  = "replaceNode"
+	FunctionRotateAdmin  = "rotateAdmin"
+)
+
+// Call is a call of a contract function, sent as the data of a transaction to the contract
+type Call struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+}
+
+// Data returns the transaction data of the call
+func (c Call) Data() (string, error) {
+	if c.Args == nil {
+		c.Args = []string{}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ParseCall reads the call back from its transaction data, e.g. a registration that has been queued
+func ParseCall(data string) (Call, error) {
+	var call Call
+	if err := json.Unmarshal([]byte(data), &call); err != nil {
+		return Call{}, err
+	}
+	if call.Function == "" {
+		return Call{}, errors.New("the call has no function")
+	}
+	return call, nil
+}
+
+// Info is the measurement a node registers
+type Info struct {
+	Data      string
+	BlkHeight uint64 `json:",string"`
+}
+
+// Register calls register with the info signed by the node. The contract verifies the signature
+// over the canonical JSON of the info, see the canonical package.
+func Register(info Info, node signer.Signer) (Call, error) {
+	infoBytes, err := canonical.Marshal(info)
+	if err != nil {
+		return Call{}, errs.Collection("encode info", err)
+	}
+	sig, err := sign(node, infoBytes)
+	if err != nil {
+		return Call{}, errs.Key("sign info", err)
+	}
+	return Call{
+		Function: FunctionRegister,
+		Args:     []string{string(infoBytes), node.Address(), hex.EncodeToString(node.PublicKey()), sig},
+	}, nil
+}
+
+// Setup calls setup with the node addresses signed by the admin. The contract verifies the
+// signature over "setup:<nonce>:<addresses joined by commas>", like a membership change, so a
+// signed setup can not be sent again. nonce is the membership nonce of the contract, see
+// Client.MembershipNonce. The first setup makes the admin's address the admin of the contract;
+// later setups must be signed by the stored admin.
+func Setup(addrs []string, nonce uint64, admin signer.Signer) (Call, error) {
+	if addrs == nil {
+		addrs = []string{}
+	}
+	if addr, ok := duplicate(addrs); ok {
+		return Call{}, errs.Config("check addresses", fmt.Errorf("%s is listed twice", addr))
+	}
+	sig, err := sign(admin, []byte(fmt.Sprintf("%s:%d:%s", FunctionSetup, nonce, strings.Join(addrs, ","))))
+	if err != nil {
+		return Call{}, errs.Key("sign addresses", err)
+	}
+	addrsJSON, err := json.Marshal(addrs)
+	if err != nil {
+		return Call{}, errs.Config("encode addresses", err)
+	}
+	return Call{
+		Function: FunctionSetup,
+		Args:     []string{string(addrsJSON), hex.EncodeToString(admin.PublicKey()), sig, admin.Address()},
+	}, nil
+}
+
+// DappSchedule calls dapp_schedule, which the nodes also call on every block to verify the current target batch
+func DappSchedule() Call {
+	return Call{Function: FunctionDappSchedule, Args: []string{}}
+}
+
+// sign signs the SHA-256 of msg and returns the hex encoded signature
+func sign(s signer.Signer, msg []byte) (string, error) {
+	digest := sha256.Sum256(msg)
+	sig, err := s.Sign(digest[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig), nil
+}
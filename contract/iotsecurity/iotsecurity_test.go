@@ -0,0 +1,125 @@
+package iotsecurity
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/signer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+const (
+	node1Addr    = "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
+	node1PubKey  = "fd2681827b0e3be73d21e3238b155fce269d7c356f2b85a74a6b0bf6514cbd345dc848a7dad99d7d61dbd8a5e08ac0b21a52b8fc575e29af6f9e089b1bbb7c82"
+	node1PrivKey = "f22bac4a73a9881d523075d9bb749ca537c7fa451366d935bcb65509968ac3e4"
+	node2Addr    = "dRE5XUM2demeG8unwsWgs1WRGSUGdgWaDo"
+)
+
+func node1Signer(t *testing.T) signer.Signer {
+	privKey, err := hex.DecodeString(node1PrivKey)
+	assert.Nil(t, err)
+	s, err := signer.NewSoftwareSigner(privKey)
+	assert.Nil(t, err)
+	return s
+}
+
+func storage(values map[string]string) func(ctx context.Context, key string) (string, error) {
+	return func(ctx context.Context, key string) (string, error) {
+		return values[key], nil
+	}
+}
+
+func TestRegister(t *testing.T) {
+	call, err := Register(Info{Data: "filetree=ab", BlkHeight: 12}, node1Signer(t))
+	assert.Nil(t, err)
+	assert.Equal(t, FunctionRegister, call.Function)
+	if assert.Len(t, call.Args, 4) {
+		assert.Equal(t, `{"BlkHeight":"12","Data":"filetree=ab"}`, call.Args[0])
+		assert.Equal(t, node1Addr, call.Args[1])
+		assert.Equal(t, node1PubKey, call.Args[2])
+		assert.NotEmpty(t, call.Args[3])
+	}
+
+	data, err := call.Data()
+	assert.Nil(t, err)
+	parsed, err := ParseCall(data)
+	assert.Nil(t, err)
+	assert.Equal(t, call, parsed)
+}
+
+func TestSetup(t *testing.T) {
+	call, err := Setup([]string{node1Addr, node2Addr}, 2, node1Signer(t))
+	assert.Nil(t, err)
+	data, err := call.Data()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"function":"setup","args":["[\"`+node1Addr+`\",\"`+node2Addr+`\"]","`+node1PubKey+`","`+call.Args[2]+`","`+node1Addr+`"]}`, data)
+	assertSignedBy(t, "setup:2:"+node1Addr+","+node2Addr, call.Args[2])
+
+	call, err = Setup(nil, 0, node1Signer(t))
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", call.Args[0])
+
+	_, err = Setup([]string{node1Addr, node1Addr}, 0, node1Signer(t))
+	assert.EqualError(t, err, "config error: check addresses: "+node1Addr+" is listed twice")
+}
+
+func TestDappSchedule(t *testing.T) {
+	data, err := DappSchedule().Data()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"function":"dapp_schedule","args":[]}`, data)
+}
+
+func TestParseCall_errors(t *testing.T) {
+	_, err := ParseCall("not json")
+	assert.NotNil(t, err)
+	_, err = ParseCall(`{"args":[]}`)
+	assert.EqualError(t, err, "the call has no function")
+}
+
+func TestReadBatch(t *testing.T) {
+	values := map[string]string{
+		"verifierStartingBlkHeight": "10",
+		"verifierAddresses":         `{"0":"` + node1Addr + `","1":"` + node2Addr + `,dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A"}`,
+	}
+	expected := map[uint64]Batch{
+		10: {Index: -1, StartingBlkHeight: 10},
+		11: {Index: 0, StartingBlkHeight: 10, Addresses: []string{node1Addr}},
+		12: {Index: 1, StartingBlkHeight: 10, Addresses: []string{node2Addr, "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A"}},
+		13: {Index: -1, StartingBlkHeight: 10},
+	}
+	for blkHeight, batch := range expected {
+		read, err := readBatch(context.Background(), storage(values), blkHeight, keyVerifierAddrs, keyVerifierStartingBlkHeight, NumOfVerifierBatches)
+		assert.Nil(t, err)
+		assert.Equal(t, batch, read, blkHeight)
+	}
+
+	read, err := readBatch(context.Background(), storage(map[string]string{}), 5, keyVerifierAddrs, keyVerifierStartingBlkHeight, NumOfVerifierBatches)
+	assert.Nil(t, err)
+	assert.Equal(t, -1, read.Index)
+
+	values["verifierAddresses"] = "not json"
+	_, err = readBatch(context.Background(), storage(values), 11, keyVerifierAddrs, keyVerifierStartingBlkHeight, NumOfVerifierBatches)
+	assert.NotNil(t, err)
+}
+
+type countingConns struct {
+	failures int
+}
+
+func (c *countingConns) Conn() *grpc.ClientConn { return nil }
+
+func (c *countingConns) ReportFailure() { c.failures++ }
+
+func TestClient_report(t *testing.T) {
+	conns := &countingConns{}
+	c := NewPoolClient(conns, "")
+	assert.Nil(t, c.report(nil))
+	assert.NotNil(t, c.report(errs.Rejected("send register", errors.New("invalid transaction"))))
+	assert.Equal(t, 0, conns.failures)
+	assert.NotNil(t, c.report(errs.Transport("send register", errors.New("connection refused"))))
+	assert.Equal(t, 1, conns.failures)
+}
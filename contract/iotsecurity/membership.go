@@ -0,0 +1,130 @@
+package iotsecurity
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/signer"
+)
+
+// AddNodes calls addNodes, which adds addrs to the node addresses of the contract. nonce is the
+// membership nonce of the contract, see Client.MembershipNonce.
+func AddNodes(addrs []string, nonce uint64, admin signer.Signer) (Call, error) {
+	return membershipChange(FunctionAddNodes, addrs, nonce, admin)
+}
+
+// RemoveNodes calls removeNodes, which removes addrs from the node addresses of the contract
+func RemoveNodes(addrs []string, nonce uint64, admin signer.Signer) (Call, error) {
+	return membershipChange(FunctionRemoveNodes, addrs, nonce, admin)
+}
+
+// ReplaceNode calls replaceNode, which puts newAddr in the place of oldAddr in the node addresses of the contract
+func ReplaceNode(oldAddr string, newAddr string, nonce uint64, admin signer.Signer) (Call, error) {
+	call, err := membershipChange(FunctionReplaceNode, []string{oldAddr, newAddr}, nonce, admin)
+	if err != nil {
+		return Call{}, err
+	}
+	// replaceNode takes the addresses as separate arguments
+	call.Args = append([]string{oldAddr, newAddr}, call.Args[1:]...)
+	return call, nil
+}
+
+// membershipChange signs a change of the node addresses. The contract verifies the signature over
+// "<function>:<nonce>:<addresses joined by commas>". The nonce changes with every change, so a
+// signed change can not be sent again.
+func membershipChange(function string, addrs []string, nonce uint64, admin signer.Signer) (Call, error) {
+	if len(addrs) == 0 {
+		return Call{}, errs.Config("check addresses", errors.New("no addresses"))
+	}
+	sig, err := sign(admin, []byte(fmt.Sprintf("%s:%d:%s", function, nonce, strings.Join(addrs, ","))))
+	if err != nil {
+		return Call{}, errs.Key("sign addresses", err)
+	}
+	addrsJSON, err := json.Marshal(addrs)
+	if err != nil {
+		return Call{}, errs.Config("encode addresses", err)
+	}
+	return Call{
+		Function: function,
+		Args:     []string{string(addrsJSON), hex.EncodeToString(admin.PublicKey()), sig},
+	}, nil
+}
+
+// WithNodes returns the node addresses after addNodes adds addrs, or an error if the contract rejects the change
+func WithNodes(nodes []string, addrs []string) ([]string, error) {
+	if addr, ok := duplicate(addrs); ok {
+		return nil, fmt.Errorf("%s is listed twice", addr)
+	}
+	for _, addr := range addrs {
+		if contains(nodes, addr) {
+			return nil, fmt.Errorf("%s is already a node of the contract", addr)
+		}
+	}
+	return append(append([]string{}, nodes...), addrs...), nil
+}
+
+// WithoutNodes returns the node addresses after removeNodes removes addrs, or an error if the contract rejects the change
+func WithoutNodes(nodes []string, addrs []string) ([]string, error) {
+	if addr, ok := duplicate(addrs); ok {
+		return nil, fmt.Errorf("%s is listed twice", addr)
+	}
+	for _, addr := range addrs {
+		if !contains(nodes, addr) {
+			return nil, fmt.Errorf("%s is not a node of the contract", addr)
+		}
+	}
+	remaining := []string{}
+	for _, node := range nodes {
+		if !contains(addrs, node) {
+			remaining = append(remaining, node)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, errors.New("removeNodes can not remove all nodes, use setup instead")
+	}
+	return remaining, nil
+}
+
+// WithNodeReplaced returns the node addresses after replaceNode puts newAddr in the place of oldAddr,
+// or an error if the contract rejects the change
+func WithNodeReplaced(nodes []string, oldAddr string, newAddr string) ([]string, error) {
+	if !contains(nodes, oldAddr) {
+		return nil, fmt.Errorf("%s is not a node of the contract", oldAddr)
+	}
+	if contains(nodes, newAddr) {
+		return nil, fmt.Errorf("%s is already a node of the contract", newAddr)
+	}
+	replaced := append([]string{}, nodes...)
+	for i, node := range replaced {
+		if node == oldAddr {
+			replaced[i] = newAddr
+			break
+		}
+	}
+	return replaced, nil
+}
+
+func contains(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicate returns the first address that is listed twice in addrs, which the contract rejects
+func duplicate(addrs []string) (string, bool) {
+	seen := map[string]bool{}
+	for _, addr := range addrs {
+		if seen[addr] {
+			return addr, true
+		}
+		seen[addr] = true
+	}
+	return "", false
+}
@@ -0,0 +1,99 @@
+package iotsecurity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/dappley/go-dappley/crypto/keystore/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+const node3Addr = "dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A"
+
+// assertSignedBy checks that sigHex is node1's signature of the SHA-256 of msg
+func assertSignedBy(t *testing.T, msg string, sigHex string) {
+	sig, err := hex.DecodeString(sigHex)
+	assert.Nil(t, err)
+	digest := sha256.Sum256([]byte(msg))
+	recovered, err := secp256k1.RecoverPubkey(digest[:], sig)
+	if assert.Nil(t, err) {
+		assert.Equal(t, node1PubKey, hex.EncodeToString(recovered[1:]))
+	}
+}
+
+func TestAddNodes(t *testing.T) {
+	call, err := AddNodes([]string{node1Addr, node2Addr}, 3, node1Signer(t))
+	assert.Nil(t, err)
+	assert.Equal(t, FunctionAddNodes, call.Function)
+	if assert.Len(t, call.Args, 3) {
+		assert.Equal(t, `["`+node1Addr+`","`+node2Addr+`"]`, call.Args[0])
+		assert.Equal(t, node1PubKey, call.Args[1])
+		assertSignedBy(t, "addNodes:3:"+node1Addr+","+node2Addr, call.Args[2])
+	}
+
+	_, err = AddNodes(nil, 3, node1Signer(t))
+	assert.NotNil(t, err)
+}
+
+func TestRemoveNodes(t *testing.T) {
+	call, err := RemoveNodes([]string{node2Addr}, 0, node1Signer(t))
+	assert.Nil(t, err)
+	assert.Equal(t, FunctionRemoveNodes, call.Function)
+	if assert.Len(t, call.Args, 3) {
+		assert.Equal(t, `["`+node2Addr+`"]`, call.Args[0])
+		assertSignedBy(t, "removeNodes:0:"+node2Addr, call.Args[2])
+	}
+}
+
+func TestReplaceNode(t *testing.T) {
+	call, err := ReplaceNode(node1Addr, node2Addr, 7, node1Signer(t))
+	assert.Nil(t, err)
+	assert.Equal(t, FunctionReplaceNode, call.Function)
+	if assert.Len(t, call.Args, 4) {
+		assert.Equal(t, node1Addr, call.Args[0])
+		assert.Equal(t, node2Addr, call.Args[1])
+		assert.Equal(t, node1PubKey, call.Args[2])
+		assertSignedBy(t, "replaceNode:7:"+node1Addr+","+node2Addr, call.Args[3])
+	}
+}
+
+func TestWithNodes(t *testing.T) {
+	nodes := []string{node1Addr}
+	added, err := WithNodes(nodes, []string{node2Addr, node3Addr})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{node1Addr, node2Addr, node3Addr}, added)
+	assert.Equal(t, []string{node1Addr}, nodes)
+
+	_, err = WithNodes(nodes, []string{node1Addr})
+	assert.EqualError(t, err, node1Addr+" is already a node of the contract")
+	_, err = WithNodes(nodes, []string{node2Addr, node2Addr})
+	assert.EqualError(t, err, node2Addr+" is listed twice")
+}
+
+func TestWithoutNodes(t *testing.T) {
+	nodes := []string{node1Addr, node2Addr, node3Addr}
+	remaining, err := WithoutNodes(nodes, []string{node2Addr})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{node1Addr, node3Addr}, remaining)
+
+	_, err = WithoutNodes(nodes, []string{"dRE5XUM2demeG8unwsWgs1WRGSUGdgWaDp"})
+	assert.NotNil(t, err)
+	_, err = WithoutNodes(nodes, nodes)
+	assert.NotNil(t, err)
+	_, err = WithoutNodes(nodes, []string{node2Addr, node2Addr})
+	assert.EqualError(t, err, node2Addr+" is listed twice")
+}
+
+func TestWithNodeReplaced(t *testing.T) {
+	nodes := []string{node1Addr, node2Addr}
+	replaced, err := WithNodeReplaced(nodes, node1Addr, node3Addr)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{node3Addr, node2Addr}, replaced)
+	assert.Equal(t, []string{node1Addr, node2Addr}, nodes)
+
+	_, err = WithNodeReplaced(nodes, node3Addr, node1Addr)
+	assert.EqualError(t, err, node3Addr+" is not a node of the contract")
+	_, err = WithNodeReplaced(nodes, node1Addr, node2Addr)
+	assert.EqualError(t, err, node2Addr+" is already a node of the contract")
+}
@@ -0,0 +1,114 @@
+// Package errs defines the typed errors shared by the monitor, setup and deploy programs.
+//
+// Every error carries a Kind that tells the caller whether retrying can help:
+// config and key errors need an operator to fix them, and a request the node has
+// rejected is rejected again if it is resent, while collection and transport errors
+// may go away on the next attempt. A call cancelled by the caller is none of them: it is
+// returned as it is and never retried.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindConfig
+	KindKey
+	KindCollection
+	KindTransport
+	KindRejected
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindConfig:
+		return "config error"
+	case KindKey:
+		return "key error"
+	case KindCollection:
+		return "collection error"
+	case KindTransport:
+		return "transport error"
+	case KindRejected:
+		return "rejected"
+	}
+	return "error"
+}
+
+type Error struct {
+	Kind Kind
+	Op   string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Kind, e.Op, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func Config(op string, err error) error     { return &Error{KindConfig, op, err} }
+func Key(op string, err error) error        { return &Error{KindKey, op, err} }
+func Collection(op string, err error) error { return &Error{KindCollection, op, err} }
+func Transport(op string, err error) error  { return &Error{KindTransport, op, err} }
+func Rejected(op string, err error) error   { return &Error{KindRejected, op, err} }
+
+// RPC classifies an error returned by a node RPC. Errors caused by the connection, and errors
+// without a gRPC status, are transport errors; errors returned by the node about the request
+// itself, including its internal and unknown errors, are rejections. A cancelled call is
+// returned unchanged.
+func RPC(op string, err error) error {
+	if IsCanceled(err) {
+		return err
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return Transport(op, err)
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted:
+		return Transport(op, err)
+	}
+	return Rejected(op, err)
+}
+
+// IsCanceled reports whether err comes from a call cancelled by the caller
+func IsCanceled(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	return errors.As(err, &se) && se.GRPCStatus().Code() == codes.Canceled
+}
+
+// KindOf returns the kind of the first *Error in the chain of err
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// IsFatal reports whether err needs an operator to fix it, i.e. a config or key error.
+func IsFatal(err error) bool {
+	switch KindOf(err) {
+	case KindConfig, KindKey:
+		return true
+	}
+	return false
+}
+
+// IsRecoverable reports whether retrying the same request may succeed. Fatal errors,
+// requests the node has rejected and cancelled calls are not retried.
+func IsRecoverable(err error) bool {
+	return !IsFatal(err) && KindOf(err) != KindRejected && !IsCanceled(err)
+}
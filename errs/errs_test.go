@@ -0,0 +1,67 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRPC(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		kind Kind
+	}{
+		{codes.Unavailable, KindTransport},
+		{codes.DeadlineExceeded, KindTransport},
+		{codes.Aborted, KindTransport},
+		{codes.ResourceExhausted, KindTransport},
+		{codes.Unknown, KindRejected},
+		{codes.Internal, KindRejected},
+		{codes.InvalidArgument, KindRejected},
+		{codes.NotFound, KindRejected},
+		{codes.FailedPrecondition, KindRejected},
+		{codes.PermissionDenied, KindRejected},
+		{codes.Unimplemented, KindRejected},
+	}
+	for _, tt := range tests {
+		err := RPC("send", status.Error(tt.code, "node error"))
+		assert.Equal(t, tt.kind, KindOf(err), tt.code.String())
+	}
+	//an error without a status is an unknown error of the connection
+	assert.Equal(t, KindTransport, KindOf(RPC("send", errors.New("connection reset"))))
+
+	//a cancelled call is neither, and is not retried
+	for _, err := range []error{status.Error(codes.Canceled, "context canceled"), context.Canceled} {
+		assert.Equal(t, err, RPC("send", err))
+		assert.False(t, IsRecoverable(RPC("send", err)))
+	}
+	assert.True(t, IsCanceled(fmt.Errorf("setup: %w", status.Error(codes.Canceled, "context canceled"))))
+}
+
+func TestIsRecoverable(t *testing.T) {
+	tests := []struct {
+		err         error
+		recoverable bool
+		fatal       bool
+	}{
+		{Config("check config", errors.New("contractAddr is required")), false, true},
+		{Key("decode key", errors.New("odd length")), false, true},
+		{Collection("measure", errors.New("permission denied")), true, false},
+		{Transport("dial", errors.New("connection refused")), true, false},
+		{Rejected("send", errors.New("invalid transaction")), false, false},
+		{errors.New("unclassified"), true, false},
+		{fmt.Errorf("setup: %w", Rejected("send", errors.New("invalid transaction"))), false, false},
+		{fmt.Errorf("monitor: %w", Key("decode key", errors.New("odd length"))), false, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.recoverable, IsRecoverable(tt.err), tt.err.Error())
+		assert.Equal(t, tt.fatal, IsFatal(tt.err), tt.err.Error())
+	}
+	assert.Equal(t, KindRejected, KindOf(fmt.Errorf("setup: %w", Rejected("send", errors.New("invalid transaction")))))
+	assert.Equal(t, KindUnknown, KindOf(nil))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dappley/iot-security/config"
+	"github.com/dappley/iot-security/contract/iotsecurity"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/keystore"
+	"github.com/dappley/iot-security/signer"
+	logger "github.com/sirupsen/logrus"
+	"path/filepath"
+	"strings"
+)
+
+const adminUsage = `  rotate [-o keystore] [-new-passphrase-file file] [-update=false] [-f file] [flags]   generate a new admin key and make it the admin of the contract
+`
+
+var adminCommands = map[string]command{
+	"rotate": runAdminRotate,
+}
+
+func runAdmin(args []string) int {
+	return runSubcommands("admin", adminCommands, adminUsage, args)
+}
+
+//runAdminRotate generates a new admin key into a keystore, has the current admin sign the rotation to it and waits until
+//the contract storage holds the new admin. The setup config is then changed to the new keystore.
+//-passphrase-file is the override of passphraseFile, the passphrase of the current keystore, so the new one has its own flag.
+func runAdminRotate(args []string) int {
+	var keystorePath, passphraseFile string
+	var update bool
+	flags := newFlagSet("admin rotate", "[-o keystore] [-new-passphrase-file file] [-update=false] [-f file] [flags]")
+	flags.StringVar(&keystorePath, "o", "", "keystore file of the new admin key (default: the config file path with the new admin address and a .keystore extension)")
+	flags.StringVar(&passphraseFile, "new-passphrase-file", "", "file containing the passphrase of the new keystore (default passphraseFile of the config)")
+	flags.BoolVar(&update, "update", true, "set adminKeystore and adminPubKey of the config file to the new key")
+	configs := newAdminSource(flags)
+	flags.Parse(args)
+
+	config, err := configs.loadContract()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+	if keystorePath == "" && configs.filePath == "" {
+		logger.Fatal("can not create the new keystore. Error:", errs.Config("check flags", errors.New("-o is required without a config file")))
+	}
+	//the new keystore keeps the passphrase of the current one, unless it is given its own
+	if passphraseFile == "" {
+		passphraseFile = config.PassphraseFile
+	}
+	adminSigner, err := initAdminSigner(config)
+	if err != nil {
+		logger.Error("can not load admin key. Error:", err)
+		return 1
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+	contractClient := iotsecurity.NewPoolClient(pool, config.ContractAddr)
+	ctx := context.Background()
+	admin, err := contractClient.Admin(ctx)
+	if err != nil {
+		logger.Error("can not read the admin of the contract. Error:", err)
+		return 1
+	}
+	if admin == "" {
+		logger.Error("The contract has no admin yet. Run setup first, which makes the admin key of the config the admin. Contracts before 2.0.0 can not rotate their admin, which is fixed in their source.")
+		return 1
+	}
+	if err := checkAdmin(ctx, contractClient, adminSigner); err != nil {
+		logger.Error("Rotation failed. Error:", err)
+		return 1
+	}
+
+	//the new key is saved before the rotation is sent, so that it can not be lost once the contract accepts it
+	newSigner, keystorePath, err := newAdminKeystore(keystorePath, configs.filePath, passphraseFile)
+	if err != nil {
+		logger.Error("can not create the new admin key. Error:", err)
+		return 1
+	}
+	logger.WithFields(logger.Fields{
+		"address":  newSigner.Address(),
+		"keystore": keystorePath,
+	}).Info("The new admin key has been created. Keep the passphrase safe, it can not be recovered!")
+
+	if err := rotateAdmin(contractClient, config, adminSigner, newSigner.Address()); err != nil {
+		logger.Error("Rotation failed. Error:", err)
+		logger.Warnf("Keep %s: the contract may still accept the rotation to %s.", keystorePath, newSigner.Address())
+		return 1
+	}
+	logger.WithFields(logger.Fields{
+		"old admin": adminSigner.Address(),
+		"new admin": newSigner.Address(),
+	}).Info("Rotation is confirmed! The old admin key can no longer sign for the contract.")
+
+	if !update || configs.filePath == "" {
+		logger.Warnf("Set adminKeystore to %s and adminPubKey to %s in the setup configs.", keystorePath, hex.EncodeToString(newSigner.PublicKey()))
+		if passphraseFile != config.PassphraseFile {
+			logger.Warnf("Set passphraseFile to %s in the setup configs.", passphraseFile)
+		}
+		return 0
+	}
+	if err := updateAdminKey(configs.filePath, keystorePath, passphraseFile, newSigner); err != nil {
+		logger.Errorf("can not update %s. Error: %v", configs.filePath, err)
+		return 1
+	}
+	if config.Signer.Type == signer.TypePKCS11 {
+		logger.Warnf("%s signs with a PKCS#11 key. Set signer to software to sign with the new keystore.", configs.filePath)
+	}
+	if config.AdminPrivKey != "" {
+		logger.Warnf("Remove the old adminPrivKey from %s.", configs.filePath)
+	}
+	return 0
+}
+
+//defaultAdminKeystorePath is the config file path with the admin address and a .keystore extension
+func defaultAdminKeystorePath(configPath string, address string) string {
+	return strings.TrimSuffix(configPath, filepath.Ext(configPath)) + "." + address + ".keystore"
+}
+
+//newAdminKeystore generates a new admin key and saves it into a keystore, like keys migrate does. It returns the signer of the
+//new key and the path of its keystore.
+func newAdminKeystore(keystorePath string, configPath string, passphraseFile string) (signer.Signer, string, error) {
+	privKey, err := signer.GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	newSigner, err := signer.NewSoftwareSigner(privKey)
+	if err != nil {
+		return nil, "", err
+	}
+	if keystorePath == "" {
+		keystorePath = defaultAdminKeystorePath(configPath, newSigner.Address())
+	}
+
+	passphrase, err := keystore.ReadPassphrase(passphraseFile, true)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(passphrase) == 0 {
+		return nil, "", errors.New("the passphrase is empty")
+	}
+	k, err := keystore.Encrypt(privKey, newSigner.Address(), hex.EncodeToString(newSigner.PublicKey()), passphrase, keystore.DefaultScryptParams)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := k.Save(keystorePath); err != nil {
+		return nil, "", err
+	}
+	if decrypted, err := k.Decrypt(passphrase); err != nil || !bytes.Equal(decrypted, privKey) {
+		return nil, "", fmt.Errorf("the keystore %s could not be verified", keystorePath)
+	}
+	return newSigner, keystorePath, nil
+}
+
+//rotateAdmin sends the rotation signed by the current admin and waits until the contract storage holds the new admin
+func rotateAdmin(contractClient *iotsecurity.Client, config AdminConfig, adminSigner signer.Signer, newAdmin string) error {
+	nonce, err := contractClient.AdminNonce(context.Background())
+	if err != nil {
+		return err
+	}
+	call, err := iotsecurity.RotateAdmin(newAdmin, nonce, adminSigner)
+	if err != nil {
+		return err
+	}
+	//like a membership change, the rotation is only confirmed once the contract has counted it
+	return sendAdminCall(contractClient, config, call, func(ctx context.Context) (bool, error) {
+		current, err := contractClient.AdminNonce(ctx)
+		if err != nil || current <= nonce {
+			return false, err
+		}
+		admin, err := contractClient.Admin(ctx)
+		return admin == newAdmin, err
+	})
+}
+
+//updateAdminKey points the setup config at the new keystore and the file of its passphrase, if it is read from a file
+func updateAdminKey(configPath string, keystorePath string, passphraseFile string, newSigner signer.Signer) error {
+	values := map[string]string{
+		"adminKeystore": keystorePath,
+		"adminPubKey":   hex.EncodeToString(newSigner.PublicKey()),
+	}
+	if passphraseFile != "" {
+		values["passphraseFile"] = passphraseFile
+	}
+	return config.SetStrings(configPath, values)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"github.com/dappley/go-dappley/client"
+	"github.com/dappley/go-dappley/storage"
+	"github.com/dappley/iot-security/config"
+	logger "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const configUsage = `  check [-wallet file] [file or directory ...]   check config files and the keys and addresses in them
+  convert [-to format] [-o file] [-force] file ...   convert config files to json, yaml or toml
+`
+
+var configCommands = map[string]command{
+	"check":   checkConfigs,
+	"convert": convertConfigs,
+}
+
+func runConfig(args []string) int {
+	return runSubcommands("config", configCommands, configUsage, args)
+}
+
+//checkConfigs prints a report for every config file and returns the exit code, 1 if any file has a problem
+func checkConfigs(args []string) int {
+	flags := newFlagSet("config check", "[-wallet file] [file or directory ...]")
+	walletPath := flags.String("wallet", client.GetWalletFilePath(), "dappley wallet file that should hold the sender addresses")
+	flags.Parse(args)
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{filepath.Dir(defaultConfigPath), defaultAdminConfigPath}
+	}
+
+	inWallet, err := loadWallet(*walletPath)
+	if err != nil {
+		logger.WithFields(logger.Fields{
+			"wallet": *walletPath,
+		}).Warn("can not load the wallet, the sender addresses are not checked. Error:", err)
+	}
+
+	reports, err := config.Check(paths, inWallet)
+	if err != nil {
+		logger.Error("Check failed. Error:", err)
+		return 1
+	}
+	code := 0
+	for _, report := range reports {
+		fmt.Println(report)
+		for _, err := range report.Errors {
+			fmt.Println("   ", err)
+		}
+		if !report.OK() {
+			code = 1
+		}
+	}
+	return code
+}
+
+func loadWallet(path string) (func(addr string) bool, error) {
+	wm := client.NewWalletManager(storage.NewFileStorage(path))
+	if err := wm.LoadFromFile(); err != nil {
+		return nil, err
+	}
+	addrs := map[string]bool{}
+	for _, addr := range wm.GetAddresses() {
+		addrs[addr.String()] = true
+	}
+	return func(addr string) bool {
+		return addrs[addr]
+	}, nil
+}
+
+//convertConfigs writes every file in the format given by -to, next to the file with the extension of the format
+func convertConfigs(args []string) int {
+	flags := newFlagSet("config convert", "[-to format] [-o file] [-force] file ...")
+	to := flags.String("to", config.FormatYAML, "format to convert to: json, yaml or toml")
+	output := flags.String("o", "", "output file, if a single file is converted")
+	force := flags.Bool("force", false, "overwrite existing files")
+	flags.Parse(args)
+	files := flags.Args()
+	if len(files) == 0 || (*output != "" && len(files) > 1) {
+		flags.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, file := range files {
+		target := *output
+		if target == "" {
+			target = strings.TrimSuffix(file, filepath.Ext(file)) + config.Extension(*to)
+		}
+		if err := convertFile(file, target, *to, *force); err != nil {
+			logger.WithFields(logger.Fields{
+				"file": file,
+			}).Error("Conversion failed. Error:", err)
+			code = 1
+			continue
+		}
+		logger.WithFields(logger.Fields{
+			"file":   file,
+			"output": target,
+		}).Info("The config has been converted")
+	}
+	return code
+}
+
+func convertFile(file string, target string, format string, force bool) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(target); err == nil && !force {
+		return fmt.Errorf("%s already exists. Use -force to overwrite it", target)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	converted, err := config.Convert(data, config.FormatOf(file), format)
+	if err != nil {
+		return err
+	}
+	if format != config.FormatJSON {
+		converted = append([]byte(fmt.Sprintf("# converted from %s\n", filepath.Base(file))), converted...)
+	}
+	//the file may hold a private key, so it keeps the permissions of the original
+	return ioutil.WriteFile(target, converted, info.Mode().Perm())
+}
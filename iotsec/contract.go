@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dappley/iot-security/contract"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/rpcclient"
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"strings"
+)
+
+const contractUsage = `  verify [-f file] [flags]   compare the deployed contract with the embedded contract source
+  source                     print the embedded contract source
+`
+
+var contractCommands = map[string]command{
+	"verify": runContractVerify,
+	"source": runContractSource,
+}
+
+func runContract(args []string) int {
+	return runSubcommands("contract", contractCommands, contractUsage, args)
+}
+
+//runContractVerify fetches the code of the contract at contractAddr from the node and compares it with the embedded source.
+//It returns 1 if they differ.
+func runContractVerify(args []string) int {
+	flags := newFlagSet("contract verify", "[-f file] [flags]")
+	configs := newAdminSource(flags)
+	flags.Parse(args)
+
+	config, err := configs.loadContract()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+	code, err := rpcclient.ContractCode(context.Background(), pool.Conn(), config.ContractAddr)
+	if err != nil {
+		logger.Error("can not read the contract. Error:", err)
+		return 1
+	}
+
+	release, known := contract.Identify(code)
+	fmt.Println("contract:         ", config.ContractAddr)
+	fmt.Println("deployed version: ", release.Version)
+	fmt.Println("deployed sha256:  ", release.Sha256)
+	fmt.Println("embedded version: ", contract.Current.Version)
+	fmt.Println("embedded sha256:  ", contract.Current.Sha256)
+	if config.ContractSha256 != "" && config.ContractSha256 != release.Sha256 {
+		fmt.Println("The deployed contract is not the one recorded in contractSha256 by deploy")
+		return 1
+	}
+	switch {
+	case code == contract.Source:
+		fmt.Println("The deployed contract matches the embedded source")
+		return 0
+	case known:
+		fmt.Printf("The deployed contract is release %s, which the monitor still registers with, but not the embedded source\n", release.Version)
+	default:
+		fmt.Printf("The deployed contract is not a known release. It differs from the embedded source from line %d\n", firstDifference(code, contract.Source))
+	}
+	return 1
+}
+
+func runContractSource(args []string) int {
+	flags := newFlagSet("contract source", "")
+	flags.Parse(args)
+	fmt.Print(contract.Source)
+	return 0
+}
+
+//firstDifference returns the first line, counted from 1, at which two sources differ
+func firstDifference(a string, b string) int {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+	for i := range aLines {
+		if i >= len(bLines) || aLines[i] != bLines[i] {
+			return i + 1
+		}
+	}
+	return len(aLines) + 1
+}
+
+//checkContract checks that the contract at contractAddr runs a known release of the contract source and, if deploy has
+//recorded the SHA-256 of its source, that it is that source. It returns the release of the contract.
+func checkContract(ctx context.Context, conn *grpc.ClientConn, contractAddr string, recordedSha256 string) (contract.Release, error) {
+	code, err := rpcclient.ContractCode(ctx, conn, contractAddr)
+	if err != nil {
+		return contract.Release{}, err
+	}
+	release, known := contract.Identify(code)
+	if recordedSha256 != "" && recordedSha256 != release.Sha256 {
+		return release, errs.Config("check contract", fmt.Errorf("the SHA-256 of the contract at %s is %s, not contractSha256 %s", contractAddr, release.Sha256, recordedSha256))
+	}
+	if !known {
+		return release, errs.Config("check contract", fmt.Errorf("the contract at %s is not a known release of iot_security.js (version %q, SHA-256 %s)", contractAddr, release.Version, release.Sha256))
+	}
+	return release, nil
+}
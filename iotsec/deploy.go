@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dappley/go-dappley/client"
+	"github.com/dappley/go-dappley/common"
+	"github.com/dappley/go-dappley/rpc/pb"
+	"github.com/dappley/iot-security/budget"
+	"github.com/dappley/iot-security/config"
+	"github.com/dappley/iot-security/confirm"
+	"github.com/dappley/iot-security/contract"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/rpcclient"
+	logger "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"path/filepath"
+)
+
+//runDeploy deploys the contract, waits until it is on chain and writes its address into the setup and common configs
+func runDeploy(args []string) int {
+	flags := newFlagSet("deploy", "[-f file] [-contract file] [-common file] [-update=false] [flags]")
+	configs := newAdminSource(flags)
+	contractPath := flags.String("contract", "", "contract source to deploy (default the embedded iot_security.js)")
+	commonPath := flags.String("common", "", "common config file to write the contract address into (default $"+config.EnvCommonConfig+", else "+defaultCommonConfigPath()+")")
+	update := flags.Bool("update", true, "write the contract address and the SHA-256 of its source into the setup and common configs")
+	flags.Parse(args)
+
+	config, err := configs.load()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+	script := []byte(contract.Source)
+	if *contractPath != "" {
+		if script, err = ioutil.ReadFile(*contractPath); err != nil {
+			logger.Fatal("can not read contract. Error:", errs.Config("read smart contract "+*contractPath, err))
+		}
+		if release, known := contract.Identify(string(script)); !known {
+			logger.WithFields(logger.Fields{
+				"contract":        *contractPath,
+				"contract_sha256": release.Sha256,
+			}).Warn("The contract is not a known release of iot_security.js. Monitors refuse to register with it unless they are forced")
+		}
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+	contractAddr, err := deploy(pool, script, config)
+	if err != nil {
+		logger.Error("Deploy failed. Error:", err)
+		return 1
+	}
+	release, _ := contract.Identify(string(script))
+	logger.WithFields(logger.Fields{
+		"contract_addr":    contractAddr,
+		"contract_version": release.Version,
+		"contract_sha256":  release.Sha256,
+	}).Info("contract has been deployed! Run setup to make the admin key of the config its admin")
+	if !*update {
+		return 0
+	}
+
+	return updateConfigs(*commonPath, configs.filePath, contractAddr, release.Sha256)
+}
+
+//updateConfigs writes the contract address and the SHA-256 of its source into the common config and the setup config, if deploy read one
+func updateConfigs(commonPath string, setupPath string, contractAddr string, hash string) int {
+	commonPath = config.FilePath(commonPath, config.EnvCommonConfig, "")
+	if commonPath == "" {
+		commonPath = defaultCommonConfigPath()
+	}
+	files := []string{commonPath}
+	if setupPath != "" {
+		files = append(files, setupPath)
+	} else {
+		logger.Warn("No setup config file is read, so only the common config is updated")
+	}
+
+	code := 0
+	for _, file := range files {
+		err := config.SetStrings(file, map[string]string{
+			"contractAddr":   contractAddr,
+			"contractSha256": hash,
+		})
+		if err != nil {
+			logger.WithFields(logger.Fields{
+				"file": file,
+			}).Error("can not write the contract address. Error:", err)
+			code = 1
+			continue
+		}
+		logger.WithFields(logger.Fields{
+			"file": file,
+		}).Info("The contract address has been written")
+	}
+	return code
+}
+
+//defaultCommonConfigPath is the common config next to the default monitor config
+func defaultCommonConfigPath() string {
+	return filepath.Join(filepath.Dir(defaultConfigPath), "common.conf")
+}
+
+//deploy sends the contract and waits until the contract address owns the contract's UTXO. It returns the contract address.
+//The contract is sent once, so that it is not deployed twice, but a failed call fails the pool over to the next endpoint.
+func deploy(pool *rpcclient.Pool, script []byte, config AdminConfig) (string, error) {
+
+	fee := budget.NewFee(config.Amount, config.Tip)
+	resp, err := rpcpb.NewAdminServiceClient(pool.Conn()).RpcSend(context.Background(), &rpcpb.SendRequest{
+		From:       config.SenderAddr,
+		To:         "",
+		Amount:     common.NewAmount(fee.Amount).Bytes(),
+		Tip:        common.NewAmount(fee.Tip).Bytes(),
+		WalletPath: client.GetWalletFilePath(),
+		Data:       string(script),
+	})
+	if err != nil {
+		err = reportFailure(pool, errs.RPC("send contract", err))
+		confirm.RecordRejected("deploy", string(script), err.Error())
+		return "", err
+	}
+	tx := confirm.NewTx("deploy", string(script))
+	logger.WithFields(logger.Fields{
+		"contract_addr" :	resp.ContractAddr,
+	}).Info("contract is sent!")
+
+	outcome := confirm.Wait(context.Background(), tx, func(ctx context.Context) (bool, error) {
+		utxos, err := rpcpb.NewRpcServiceClient(pool.Conn()).RpcGetUTXO(ctx, &rpcpb.GetUTXORequest{Address: resp.ContractAddr})
+		if err != nil {
+			return false, reportFailure(pool, errs.RPC("get contract utxo", err))
+		}
+		return len(utxos.Utxos) > 0, nil
+	}, config.Confirm)
+	if outcome != confirm.Confirmed {
+		return "", errs.Rejected("confirm contract", fmt.Errorf("the deployment is %s", outcome))
+	}
+	return resp.ContractAddr, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/dappley/iot-security/config"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/rpcclient"
+	logger "github.com/sirupsen/logrus"
+	"os"
+)
+
+const usage = `usage: iotsec <command> [arguments]
+
+commands:
+  monitor   measure the device and register the measurements in the contract
+  setup     set, add, remove or replace the node addresses of the contract
+  deploy    deploy the contract
+  contract  compare the deployed contract with the embedded contract source
+  status    show the node connection, the wallet balance and the registration window of a device
+  keys      move plaintext private keys into keystores and show the keys of config files
+  config    check and convert config files
+  verify    check the registrations of the nodes in the contract storage
+  admin     rotate the admin key of the contract
+
+Run iotsec <command> -h for the arguments of a command.
+`
+
+//default config files, relative to the project root folder
+const (
+	defaultConfigPath      = "conf/default.conf"
+	defaultAdminConfigPath = "setup/default.conf"
+)
+
+//command runs a subcommand with its arguments and returns the exit code
+type command func(args []string) int
+
+var commands = map[string]command{
+	"monitor":  runMonitor,
+	"setup":    runSetup,
+	"deploy":   runDeploy,
+	"contract": runContract,
+	"status":   runStatus,
+	"keys":     runKeys,
+	"config":   runConfig,
+	"verify":   runVerify,
+	"admin":    runAdmin,
+}
+
+func main() {
+
+	logger.SetFormatter(&logger.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	run, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+	os.Exit(run(flag.Args()[1:]))
+}
+
+//flagErrorHandling is how the flags of the subcommands handle a parse error. The tests make them panic instead of exiting
+var flagErrorHandling = flag.ExitOnError
+
+//newFlagSet creates the flags of a subcommand. args describes its arguments in the usage
+func newFlagSet(name string, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flagErrorHandling)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: iotsec %s %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+//runSubcommands runs the subcommand of a command, e.g. keys migrate
+func runSubcommands(name string, subcommands map[string]command, usage string, args []string) int {
+	if len(args) > 0 {
+		if run, ok := subcommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "usage: iotsec %s <command> [arguments]\n\ncommands:\n%s", name, usage)
+	return 2
+}
+
+//adminSource tells where setup, deploy and verify read their config from.
+//Flags take precedence over IOTSEC_* environment variables, which take precedence over the config file.
+type adminSource struct {
+	filePath  string
+	overrides *config.Overrides
+}
+
+func newAdminSource(flags *flag.FlagSet) *adminSource {
+	source := &adminSource{overrides: config.NewOverrides(AdminConfig{})}
+	flags.StringVar(&source.filePath, "f", "", "config file path (default "+defaultAdminConfigPath+", if it exists)")
+	source.overrides.Register(flags)
+	return source
+}
+
+//load reads the config file, if any, with the flags and IOTSEC_* environment variables taking precedence over it
+func (source *adminSource) load() (AdminConfig, error) {
+	source.filePath = config.FilePath(source.filePath, config.EnvConfig, defaultAdminConfigPath)
+	return config.LoadAdmin(source.filePath, source.overrides)
+}
+
+//loadContract loads the config like load, and also requires contractAddr, for the commands that call the deployed contract
+func (source *adminSource) loadContract() (AdminConfig, error) {
+	source.filePath = config.FilePath(source.filePath, config.EnvConfig, defaultAdminConfigPath)
+	return config.LoadContractAdmin(source.filePath, source.overrides)
+}
+
+//reportFailure fails the pool over to the next endpoint if err is caused by the connection, and returns err
+func reportFailure(pool *rpcclient.Pool, err error) error {
+	if errs.KindOf(err) == errs.KindTransport {
+		pool.ReportFailure()
+	}
+	return err
+}
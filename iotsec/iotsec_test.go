@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+//parseHelp runs a command with -h, which panics with flag.ErrHelp once all its flags are defined
+func parseHelp(run command, args []string) (recovered interface{}) {
+	defer func() {
+		recovered = recover()
+	}()
+	run(append(args, "-h"))
+	return nil
+}
+
+//TestCommands_flags defines the flags of every subcommand, which panics if two of its flags have the same name
+func TestCommands_flags(t *testing.T) {
+	flagErrorHandling = flag.PanicOnError
+	//keeps the usage of the commands out of the test output
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	stderr := os.Stderr
+	os.Stderr = devNull
+	defer func() {
+		flagErrorHandling = flag.ExitOnError
+		os.Stderr = stderr
+		devNull.Close()
+	}()
+
+	subcommands := map[string]map[string]command{
+		"admin":    adminCommands,
+		"config":   configCommands,
+		"contract": contractCommands,
+		"keys":     keysCommands,
+		"setup":    setupCommands,
+	}
+	for name, run := range commands {
+		if _, ok := subcommands[name]; !ok || name == "setup" {
+			assert.Equal(t, flag.ErrHelp, parseHelp(run, nil), name)
+		}
+		for subname := range subcommands[name] {
+			assert.Equal(t, flag.ErrHelp, parseHelp(run, []string{subname}), name+" "+subname)
+		}
+	}
+}
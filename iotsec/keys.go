@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dappley/iot-security/config"
+	"github.com/dappley/iot-security/keystore"
+	"github.com/dappley/iot-security/signer"
+	logger "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//keyField describes where a config file keeps a plaintext private key
+type keyField struct {
+	privKey  string
+	keystore string
+	address  string
+	pubKey   string
+}
+
+var keyFields = []keyField{
+	{privKey: "nodePrivateKey", keystore: "nodeKeystore", address: "nodeAddr", pubKey: "nodePubKey"},
+	{privKey: "adminPrivKey", keystore: "adminKeystore", pubKey: "adminPubKey"},
+}
+
+const keysUsage = `  migrate [-o keystore] [-passphrase-file file] -f file   move the plaintext private key of a config file into a keystore
+  node [-f file] [flags]    show the address and public key of the node key of a monitor config
+  admin [-f file] [flags]   show the address and public key of the admin key of a setup config
+`
+
+var keysCommands = map[string]command{
+	"migrate": runMigrate,
+	"node":    runNodeKey,
+	"admin":   runAdminKey,
+}
+
+func runKeys(args []string) int {
+	return runSubcommands("keys", keysCommands, keysUsage, args)
+}
+
+func runMigrate(args []string) int {
+	var filePath, keystorePath, passphraseFile string
+	flags := newFlagSet("keys migrate", "[-o keystore] [-passphrase-file file] -f file")
+	flags.StringVar(&filePath, "f", "", "config file with a plaintext private key")
+	flags.StringVar(&keystorePath, "o", "", "keystore file to create (default: the config file path with a .keystore extension)")
+	flags.StringVar(&passphraseFile, "passphrase-file", "", "file containing the keystore passphrase")
+	flags.Parse(args)
+
+	if filePath == "" {
+		flags.Usage()
+		return 2
+	}
+	if keystorePath == "" {
+		keystorePath = strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".keystore"
+	}
+
+	if err := migrate(filePath, keystorePath, passphraseFile); err != nil {
+		logger.Error("Migration failed. Error:", err)
+		return 1
+	}
+	logger.WithFields(logger.Fields{
+		"config":   filePath,
+		"keystore": keystorePath,
+	}).Info("The private key has been moved into the keystore. Keep the passphrase safe, it can not be recovered!")
+	return 0
+}
+
+//runNodeKey prints the address and public key of the node key of a monitor config
+func runNodeKey(args []string) int {
+	var filePath string
+	flags := newFlagSet("keys node", "[-f file] [flags]")
+	flags.StringVar(&filePath, "f", "", "config file path (default "+defaultConfigPath+", if it exists)")
+	overrides := config.NewOverrides(Config{})
+	overrides.Register(flags)
+	flags.Parse(args)
+	filePath = config.FilePath(filePath, config.EnvConfig, defaultConfigPath)
+
+	config, err := config.LoadMonitor(filePath, overrides)
+	if err != nil {
+		logger.Error("can not read config file. Error:", err)
+		return 1
+	}
+	nodeSigner, err := initSigner(&config)
+	if err != nil {
+		logger.Error("can not load node key. Error:", err)
+		return 1
+	}
+	printKey(nodeSigner)
+	return 0
+}
+
+//runAdminKey prints the address and public key of the admin key of a setup config
+func runAdminKey(args []string) int {
+	flags := newFlagSet("keys admin", "[-f file] [flags]")
+	configs := newAdminSource(flags)
+	flags.Parse(args)
+
+	config, err := configs.load()
+	if err != nil {
+		logger.Error("can not read config file. Error:", err)
+		return 1
+	}
+	adminSigner, err := initAdminSigner(config)
+	if err != nil {
+		logger.Error("can not load admin key. Error:", err)
+		return 1
+	}
+	printKey(adminSigner)
+	return 0
+}
+
+func printKey(keySigner signer.Signer) {
+	fmt.Println("address:   ", keySigner.Address())
+	fmt.Println("public key:", hex.EncodeToString(keySigner.PublicKey()))
+}
+
+func migrate(filePath string, keystorePath string, passphraseFile string) error {
+	raw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	config := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return fmt.Errorf("can not parse %s: %v", filePath, err)
+	}
+
+	field, privKeyHex, err := findPrivateKey(config)
+	if err != nil {
+		return err
+	}
+	privKey, err := hex.DecodeString(privKeyHex)
+	if err != nil || len(privKey) != 32 {
+		return fmt.Errorf("%s is not a hex encoded secp256k1 private key", field.privKey)
+	}
+
+	passphrase, err := keystore.ReadPassphrase(passphraseFile, true)
+	if err != nil {
+		return err
+	}
+	if len(passphrase) == 0 {
+		return errors.New("the passphrase is empty")
+	}
+
+	k, err := keystore.Encrypt(privKey, getString(config, field.address), getString(config, field.pubKey), passphrase, keystore.DefaultScryptParams)
+	if err != nil {
+		return err
+	}
+	if err := k.Save(keystorePath); err != nil {
+		return err
+	}
+	//make sure the key can be read back before it is removed from the config
+	if decrypted, err := k.Decrypt(passphrase); err != nil || !bytes.Equal(decrypted, privKey) {
+		os.Remove(keystorePath)
+		return errors.New("the keystore could not be verified")
+	}
+
+	deleteKey(config, field.privKey)
+	deleteKey(config, field.keystore)
+	config[field.keystore], _ = json.Marshal(keystorePath)
+	return writeConfig(filePath, config)
+}
+
+func findPrivateKey(config map[string]json.RawMessage) (keyField, string, error) {
+	for _, field := range keyFields {
+		if value := getString(config, field.privKey); value != "" {
+			return field, value, nil
+		}
+	}
+	return keyField{}, "", errors.New("no plaintext private key is found in the config file")
+}
+
+//getString looks up a key case-insensitively, like encoding/json does when decoding into a struct
+func getString(config map[string]json.RawMessage, key string) string {
+	for k, raw := range config {
+		if key != "" && strings.EqualFold(k, key) {
+			var value string
+			if json.Unmarshal(raw, &value) == nil {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+func deleteKey(config map[string]json.RawMessage, key string) {
+	for k := range config {
+		if strings.EqualFold(k, key) {
+			delete(config, k)
+		}
+	}
+}
+
+func writeConfig(filePath string, config map[string]json.RawMessage) error {
+	raw, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	tmp := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(raw, '\n'), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filePath)
+}
@@ -0,0 +1,586 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/dappley/go-dappley/rpc/pb"
+	"github.com/dappley/iot-security/blocks"
+	"github.com/dappley/iot-security/budget"
+	"github.com/dappley/iot-security/collector"
+	"github.com/dappley/iot-security/config"
+	"github.com/dappley/iot-security/confirm"
+	"github.com/dappley/iot-security/contract/iotsecurity"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/keystore"
+	"github.com/dappley/iot-security/measure"
+	"github.com/dappley/iot-security/queue"
+	"github.com/dappley/iot-security/retry"
+	"github.com/dappley/iot-security/rpcclient"
+	"github.com/dappley/iot-security/schedule"
+	"github.com/dappley/iot-security/signer"
+	metrics "github.com/rcrowley/go-metrics"
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+type Config = config.Monitor
+
+type CommonConfig = config.Common
+
+const metricsLogInterval = time.Minute * 10
+
+//time given to the sends in flight to finish on shutdown
+const drainTimeout = time.Second * 10
+
+//event topic of the node's block subscription
+const newBlockTopic = "NewBlock"
+
+//runMonitor monitors the device until it is stopped with SIGINT or SIGTERM
+func runMonitor(args []string) int {
+	flags := newFlagSet("monitor", "[-f file] [-common file] [flags]")
+	configs := newConfigSource(flags)
+	force := flags.Bool("force", false, "register even if the contract is not a known release of iot_security.js")
+	flags.Parse(args)
+	configs.resolvePaths()
+
+	config, commonConfig, err := configs.load()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+
+	//the config as it is in the file, before the signer fills in the node address and public key
+	fileConfig := config
+	nodeSigner, err := initSigner(&config)
+	if err != nil {
+		logger.Fatal("can not load node key. Error:", err)
+	}
+
+	s, err := newSettings(config, commonConfig)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	s.forceContract = *force
+
+	registrations, err := queue.Open(filepath.Join(getStateDir(config), "queue"))
+	if err != nil {
+		logger.Fatal("can not open registration queue. Error:", err)
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+
+	//ctx stops the monitoring on SIGINT or SIGTERM. rpcCtx aborts the sends still in flight drainTimeout later
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	rpcCtx, cancelRPC := context.WithCancel(context.Background())
+	defer cancelRPC()
+	reloads := handleSignals(stop, cancelRPC)
+
+	source := blocks.NewSource(blockHeight(pool), subscribeBlocks(pool), config.Blocks, s.retryPolicy)
+	go source.Run(ctx)
+	go metrics.Log(metrics.DefaultRegistry, metricsLogInterval, logger.StandardLogger())
+	logger.WithFields(logger.Fields{
+		"queued_registrations": registrations.Len(),
+	}).Info("Iot Security Monitoring software starts...")
+
+	failures := 0
+	//fail handles a recoverable error and returns the delay before monitoring is retried
+	fail := func(err error) time.Duration {
+		if errs.IsFatal(err) {
+			logger.Fatal("Monitoring stopped. Error:", err)
+		}
+		if errs.KindOf(err) == errs.KindTransport {
+			pool.ReportFailure()
+		}
+		failures++
+		if s.retryPolicy.Exhausted(failures) {
+			logger.Fatal("Monitoring stopped after ", failures, " consecutive failures. Error:", err)
+		}
+		delay := s.retryPolicy.Backoff(failures)
+		logger.WithFields(logger.Fields{
+			"failures":             failures,
+			"retry_in":             delay,
+			"queued_registrations": registrations.Len(),
+		}).Warn("Monitoring failed. Error:", err)
+		return delay
+	}
+
+	//registrations that have been sent and wait for confirmation, by queue sequence number
+	sent := make(map[uint64]confirm.Tx)
+	var blkHeight, currBlkHeight uint64
+	var retryTimer <-chan time.Time
+	for {
+		select {
+		case blkHeight = <-source.Heights():
+		case <-retryTimer:
+		case err := <-source.Errors():
+			//the source retries by itself, with its own backoff, so its failures do not count toward MaxAttempts
+			if errs.KindOf(err) == errs.KindTransport {
+				pool.ReportFailure()
+			}
+			logger.WithFields(logger.Fields{
+				"queued_registrations": registrations.Len(),
+			}).Warn("Unable to follow the blocks. Retrying. Error:", err)
+			continue
+		case <-reloads:
+			reloadedFileConfig, reloaded, err := reloadSettings(configs, fileConfig, s)
+			if err == nil && reloaded.commonConfig != s.commonConfig {
+				err = checkContractRelease(rpcCtx, pool.Conn(), reloaded)
+			}
+			if err != nil {
+				logger.Error("Unable to reload the config. Keeping the running config. Error:", err)
+				continue
+			}
+			fileConfig, s = reloadedFileConfig, reloaded
+			logger.Info("Config is reloaded")
+			continue
+		case <-ctx.Done():
+			//queued registrations are on disk already and are sent after a restart
+			logger.WithFields(logger.Fields{
+				"queued_registrations": registrations.Len(),
+			}).Info("Iot Security Monitoring software stopped")
+			return 0
+		}
+		retryTimer = nil
+		currBlkHeight, err = monitor(rpcCtx, pool.Conn(), s, registrations, sent, nodeSigner, blkHeight, currBlkHeight)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			retryTimer = time.After(fail(err))
+			continue
+		}
+		failures = 0
+	}
+}
+
+//handleSignals stops the monitoring on SIGINT or SIGTERM and gives the sends in flight drainTimeout to finish.
+//A second signal kills the monitor right away. It returns the reload requests sent with SIGHUP.
+func handleSignals(stop func(), cancelRPC func()) <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	reloads := make(chan struct{}, 1)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				select {
+				case reloads <- struct{}{}:
+				default:
+				}
+				continue
+			}
+			logger.WithFields(logger.Fields{
+				"signal": sig,
+			}).Info("Shutting down. Waiting for the sends in flight...")
+			signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+			stop()
+			time.AfterFunc(drainTimeout, cancelRPC)
+			return
+		}
+	}()
+	return reloads
+}
+
+//settings hold what the monitor builds from its config. They are rebuilt when the config is reloaded
+type settings struct {
+	config       Config
+	commonConfig CommonConfig
+	collectors   []collector.Collector
+	spending     *budget.Tracker
+	retryPolicy  retry.Policy
+	//forceContract registers with a contract that is not a known release
+	forceContract bool
+	//contractChecked is set once the contract has been checked against the known releases
+	contractChecked bool
+}
+
+func newSettings(config Config, commonConfig CommonConfig) (*settings, error) {
+	collectors, err := collector.New(config.Collectors, collector.Settings{
+		Paths:    getMonitoredPaths(config),
+		StateDir: getStateDir(config),
+	})
+	if err != nil {
+		return nil, errs.Config("collectors", err)
+	}
+	spending, err := budget.Open(filepath.Join(getStateDir(config), "budget.json"), config.Budget)
+	if err != nil {
+		return nil, errs.Config("budget", err)
+	}
+	return &settings{
+		config:       config,
+		commonConfig: commonConfig,
+		collectors:   collectors,
+		spending:     spending,
+		retryPolicy:  retry.NewPolicy(config.Retry),
+	}, nil
+}
+
+//reloadSettings reads the config files and overrides again. The settings used only at startup keep their running values.
+//It returns the config as it is read and the new settings.
+func reloadSettings(configs *configSource, fileConfig Config, running *settings) (Config, *settings, error) {
+	newFileConfig, commonConfig, err := configs.load()
+	if err != nil {
+		return fileConfig, running, err
+	}
+	if !reflect.DeepEqual(withStartupSettings(Config{}, fileConfig), withStartupSettings(Config{}, newFileConfig)) {
+		logger.Warn("The node key, signer, endpoints, TLS, state directory and block settings only change after a restart")
+	}
+	s, err := newSettings(withStartupSettings(newFileConfig, running.config), commonConfig)
+	if err != nil {
+		return fileConfig, running, err
+	}
+	s.forceContract = running.forceContract
+	s.contractChecked = running.contractChecked && commonConfig == running.commonConfig
+	return newFileConfig, s, nil
+}
+
+//withStartupSettings returns config with the settings that are only used at startup taken from from
+func withStartupSettings(config Config, from Config) Config {
+	config.NodeAddr = from.NodeAddr
+	config.NodePubkey = from.NodePubkey
+	config.NodePrivateKey = from.NodePrivateKey
+	config.NodeKeystore = from.NodeKeystore
+	config.PassphraseFile = from.PassphraseFile
+	config.Signer = from.Signer
+	config.RpcPort = from.RpcPort
+	config.Endpoints = from.Endpoints
+	config.TLS = from.TLS
+	config.StateDir = from.StateDir
+	config.Blocks = from.Blocks
+	return config
+}
+
+//monitor is called with blkHeight, the block the loop has just seen. It registers the measurements once per target cycle
+//of the contract, and only while the contract can still accept the registration. It then sends the queued registrations
+//and returns the block height that has been registered for.
+func monitor(ctx context.Context, conn *grpc.ClientConn, s *settings, registrations *queue.Queue, sent map[uint64]confirm.Tx, nodeSigner signer.Signer, blkHeight uint64, currBlkHeight uint64) (uint64, error) {
+	contractClient := iotsecurity.NewClient(conn, s.commonConfig.ContractAddr)
+
+	if !s.contractChecked {
+		if err := checkContractRelease(ctx, conn, s); err != nil {
+			return currBlkHeight, err
+		}
+	}
+	window, err := schedule.Read(ctx, contractClient.Query, s.config.NodeAddr)
+	if err != nil {
+		return currBlkHeight, err
+	}
+	if !window.Accepts(blkHeight) {
+		logger.WithFields(logger.Fields{
+			"blk_height":        blkHeight,
+			"target_blk_height": window.Start,
+		}).Debug("Skipped registration. Reason: ", window.Reason(blkHeight))
+	} else if !window.Registered && window.Start > currBlkHeight {
+		allowed, err := allowRegistration(ctx, conn, s.spending, blkHeight, window, s.config)
+		if err != nil {
+			return currBlkHeight, err
+		}
+		//the contract only accepts the starting block height of the target batches
+		if allowed {
+			if err := register(s.collectors, registrations, nodeSigner, window.Start, s.config); err != nil {
+				return currBlkHeight, err
+			}
+		}
+		currBlkHeight = window.Start
+	}
+	if registrations.Len() > 0 {
+		if err := sendQueuedRegistrations(ctx, contractClient, registrations, sent, s.spending, blkHeight, window, s.config); err != nil {
+			return currBlkHeight, err
+		}
+	}
+	return currBlkHeight, nil
+}
+
+//checkContractRelease refuses to register with a contract that is not a known release of iot_security.js, unless the monitor is forced to
+func checkContractRelease(ctx context.Context, conn *grpc.ClientConn, s *settings) error {
+	release, err := checkContract(ctx, conn, s.commonConfig.ContractAddr, s.commonConfig.ContractSha256)
+	if err != nil {
+		if !s.forceContract || !canForceContract(err) {
+			return err
+		}
+		logger.Warn("Registering with a contract that is not verified, since the monitor is forced to. Error:", err)
+	} else {
+		logger.WithFields(logger.Fields{
+			"contract_addr":    s.commonConfig.ContractAddr,
+			"contract_version": release.Version,
+		}).Info("The contract is a known release")
+	}
+	s.contractChecked = true
+	return nil
+}
+
+//canForceContract tells whether monitor -force registers with the contract despite err. A contract that does not match
+//contractSha256 or is not a known release can be forced, but a missing contract can not.
+func canForceContract(err error) bool {
+	return errs.KindOf(err) == errs.KindConfig && !errors.Is(err, rpcclient.ErrNoContract)
+}
+
+func getBlockHeight(ctx context.Context, serviceClient rpcpb.RpcServiceClient) (uint64, error) {
+	bcResp, err := serviceClient.RpcGetBlockchainInfo(ctx, &rpcpb.GetBlockchainInfoRequest{})
+	if err != nil {
+		return 0, errs.RPC("get block height", err)
+	}
+	return bcResp.BlockHeight, nil
+}
+
+//blockHeight reads the block height from the current endpoint of the pool
+func blockHeight(pool *rpcclient.Pool) blocks.HeightFunc {
+	return func(ctx context.Context) (uint64, error) {
+		return getBlockHeight(ctx, rpcpb.NewRpcServiceClient(pool.Conn()))
+	}
+}
+
+//subscribeBlocks subscribes to the new block events of the current endpoint of the pool
+func subscribeBlocks(pool *rpcclient.Pool) blocks.SubscribeFunc {
+	return func(ctx context.Context) (func() error, error) {
+		stream, err := rpcpb.NewRpcServiceClient(pool.Conn()).RpcSubscribe(ctx, &rpcpb.SubscribeRequest{
+			Topics: []string{newBlockTopic},
+		})
+		if err != nil {
+			return nil, errs.RPC("subscribe to blocks", err)
+		}
+		return func() error {
+			if _, err := stream.Recv(); err != nil {
+				return errs.RPC("receive block", err)
+			}
+			return nil
+		}, nil
+	}
+}
+
+//configSource tells where the monitor and status read their config from, so that a reload reads it the same way.
+//Flags take precedence over IOTSEC_* environment variables, which take precedence over the config files.
+type configSource struct {
+	filePath        string
+	commonPath      string
+	overrides       *config.Overrides
+	commonOverrides *config.Overrides
+}
+
+func newConfigSource(flags *flag.FlagSet) *configSource {
+	source := &configSource{
+		overrides:       config.NewOverrides(Config{}),
+		commonOverrides: config.NewOverrides(CommonConfig{}),
+	}
+	flags.StringVar(&source.filePath, "f", "", "config file path (default "+defaultConfigPath+", if it exists)")
+	flags.StringVar(&source.commonPath, "common", "", "common config file path (default common.conf in the folder of the config file, if it exists)")
+	source.overrides.Register(flags)
+	source.commonOverrides.Register(flags)
+	return source
+}
+
+//resolvePaths falls back to the IOTSEC_CONFIG and IOTSEC_COMMON_CONFIG environment variables and the default files
+func (source *configSource) resolvePaths() {
+	source.filePath = config.FilePath(source.filePath, config.EnvConfig, defaultConfigPath)
+	defaultCommonPath := "common.conf"
+	if source.filePath != "" {
+		defaultCommonPath = filepath.Join(filepath.Dir(source.filePath), "common.conf")
+	}
+	source.commonPath = config.FilePath(source.commonPath, config.EnvCommonConfig, defaultCommonPath)
+}
+
+func (source *configSource) load() (Config, CommonConfig, error) {
+	monitorConfig, err := config.LoadMonitor(source.filePath, source.overrides)
+	if err != nil {
+		return Config{}, CommonConfig{}, err
+	}
+	commonConfig, err := config.LoadCommon(source.commonPath, source.commonOverrides)
+	if err != nil {
+		return Config{}, CommonConfig{}, err
+	}
+	return monitorConfig, commonConfig, nil
+}
+
+//register signs the current measurements and queues the registration until it can be sent
+func register(collectors []collector.Collector, registrations *queue.Queue, nodeSigner signer.Signer, blkHeight uint64, config Config) error {
+
+	measurements, err := collector.CollectAll(collectors)
+	if err != nil {
+		return errs.Collection("collect measurements", err)
+	}
+
+	call, err := iotsecurity.Register(iotsecurity.Info{Data: measurements, BlkHeight: blkHeight}, nodeSigner)
+	if err != nil {
+		return err
+	}
+	data, err := call.Data()
+	if err != nil {
+		return errs.Collection("encode function", err)
+	}
+
+	if _, err := registrations.Push(blkHeight, data); err != nil {
+		return err
+	}
+	return nil
+}
+
+//sendQueuedRegistrations replays queued registrations in order and stops at the first failed send.
+//A sent registration stays queued until the contract storage confirms it, or until the contract can no longer accept it.
+//Registrations that the contract can no longer accept are dropped.
+func sendQueuedRegistrations(ctx context.Context, contractClient *iotsecurity.Client, registrations *queue.Queue, sent map[uint64]confirm.Tx, spending *budget.Tracker, currBlkHeight uint64, window schedule.Window, config Config) error {
+	entries, err := registrations.Entries()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if tx, ok := sent[entry.Seq]; ok {
+			//the sender's transactions are mined in order, so a stored registration at least as recent as this one confirms it
+			if window.RegisteredHeight >= entry.BlkHeight {
+				confirm.Record(tx, confirm.Confirmed, "")
+				logger.Info("Registered! BlockHeight:", entry.BlkHeight)
+			} else if reason := dropReason(entry, currBlkHeight, window); reason != "" {
+				confirm.Record(tx, confirm.TimedOut, reason)
+			} else {
+				continue
+			}
+			delete(sent, entry.Seq)
+			if err := registrations.Remove(entry.Seq); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if reason := dropReason(entry, currBlkHeight, window); reason != "" {
+			logger.WithFields(logger.Fields{
+				"blk_height":        entry.BlkHeight,
+				"curr_blk_height":   currBlkHeight,
+				"target_blk_height": window.Start,
+				"queued_at":         entry.CreatedAt,
+			}).Warn("Dropped queued registration. Reason: ", reason)
+			if err := registrations.Remove(entry.Seq); err != nil {
+				return err
+			}
+			continue
+		}
+
+		call, err := iotsecurity.ParseCall(entry.Data)
+		if err != nil {
+			logger.WithFields(logger.Fields{
+				"blk_height": entry.BlkHeight,
+				"queued_at":  entry.CreatedAt,
+			}).Warn("Dropped queued registration. Reason: it can not be read. Error:", err)
+			if err := registrations.Remove(entry.Seq); err != nil {
+				return err
+			}
+			continue
+		}
+		fee := budget.NewFee(config.Amount, config.Tip)
+		err = contractClient.Send(ctx, call, iotsecurity.Sender{From: config.SenderAddr, Fee: fee})
+		if err != nil {
+			if errs.KindOf(err) != errs.KindRejected {
+				return err
+			}
+			//the node will never accept this registration, so retrying it would block the queue
+			confirm.RecordRejected("register", entry.Data, err.Error())
+			if err := registrations.Remove(entry.Seq); err != nil {
+				return err
+			}
+			continue
+		}
+		sent[entry.Seq] = confirm.NewTx("register", entry.Data)
+		if err := spending.Spend(fee.Cost(), currBlkHeight, time.Now()); err != nil {
+			logger.Warn("Unable to record the spending. Error:", err)
+		}
+	}
+	return nil
+}
+
+//allowRegistration checks the sender's balance and the spending budget before a registration is signed
+func allowRegistration(ctx context.Context, conn *grpc.ClientConn, spending *budget.Tracker, blkHeight uint64, window schedule.Window, config Config) (bool, error) {
+	resp, err := rpcpb.NewRpcServiceClient(conn).RpcGetBalance(ctx, &rpcpb.GetBalanceRequest{
+		Address: config.SenderAddr,
+	})
+	if err != nil {
+		return false, errs.RPC("get balance", err)
+	}
+	balance := uint64(0)
+	if resp.Amount > 0 {
+		balance = uint64(resp.Amount)
+	}
+	allowed, reason := spending.Allow(budget.NewFee(config.Amount, config.Tip).Cost(), balance, blkHeight, time.Now())
+	if !allowed {
+		logger.WithFields(logger.Fields{
+			"target_blk_height": window.Start,
+			"balance":           balance,
+		}).Warn("Skipped registration. Reason: ", reason)
+	}
+	return allowed, nil
+}
+
+//dropReason tells why the contract can no longer accept a queued registration, or returns "" if it can
+func dropReason(entry queue.Entry, currBlkHeight uint64, window schedule.Window) string {
+	switch {
+	case entry.BlkHeight != window.Start:
+		return "the contract has moved on to another target cycle"
+	case window.Registered:
+		return "the node has already registered for this target cycle"
+	case !window.Accepts(currBlkHeight):
+		return window.Reason(currBlkHeight)
+	}
+	return ""
+}
+
+//initSigner creates the node signer. The node address and public key come from the signer,
+//so nodeAddr and nodePubkey in the config are only checked against it.
+func initSigner(config *Config) (signer.Signer, error) {
+	nodeSigner, err := signer.New(config.Signer, func() ([]byte, error) {
+		return loadNodeKey(*config)
+	})
+	if err != nil {
+		return nil, errs.Key("create node signer", err)
+	}
+	if config.NodeAddr != "" && config.NodeAddr != nodeSigner.Address() {
+		return nil, errs.Key("check node key", fmt.Errorf("nodeAddr %s does not match the signer address %s", config.NodeAddr, nodeSigner.Address()))
+	}
+	pubKey := hex.EncodeToString(nodeSigner.PublicKey())
+	if config.NodePubkey != "" && config.NodePubkey != pubKey {
+		return nil, errs.Key("check node key", fmt.Errorf("nodePubkey does not match the signer public key %s", pubKey))
+	}
+	config.NodeAddr = nodeSigner.Address()
+	config.NodePubkey = pubKey
+	return nodeSigner, nil
+}
+
+//loadNodeKey decrypts the node private key from its keystore. Plaintext keys in the config are deprecated.
+func loadNodeKey(config Config) ([]byte, error) {
+	if config.NodeKeystore != "" {
+		privKey, err := keystore.LoadKey(config.NodeKeystore, config.PassphraseFile)
+		if err != nil {
+			return nil, errs.Key("load keystore "+config.NodeKeystore, err)
+		}
+		return privKey, nil
+	}
+	logger.Warn("The node private key is stored in plaintext. Use iotsec keys migrate to move it into an encrypted keystore.")
+	privKey, err := hex.DecodeString(config.NodePrivateKey)
+	if err != nil {
+		return nil, errs.Key("decode node private key", err)
+	}
+	return privKey, nil
+}
+
+//getMonitoredPaths falls back to the single monitorPath of older configs
+func getMonitoredPaths(config Config) []measure.Policy {
+	if len(config.Paths) == 0 && config.MonitorPath != "" {
+		return []measure.Policy{{Path: config.MonitorPath, Change: measure.PolicyImmutable}}
+	}
+	return config.Paths
+}
+
+func getStateDir(config Config) string {
+	if config.StateDir != "" {
+		return config.StateDir
+	}
+	return filepath.Join("state", config.NodeAddr)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dappley/iot-security/confirm"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/queue"
+	"github.com/dappley/iot-security/rpcclient"
+	"github.com/dappley/iot-security/schedule"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCanForceContract(t *testing.T) {
+	assert.True(t, canForceContract(errs.Config("check contract", errors.New("the contract is not a known release of iot_security.js"))))
+	assert.False(t, canForceContract(errs.Config("get contract code", fmt.Errorf("%w at %s", rpcclient.ErrNoContract, "cfSr89kUCpKqtHFa2mXJbrkMTEBR8bDLY8"))))
+	assert.False(t, canForceContract(errs.RPC("get contract utxo", errors.New("unavailable"))))
+}
+
+func TestSendQueuedRegistrations_confirmed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrations")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	registrations, err := queue.Open(dir)
+	assert.Nil(t, err)
+
+	mined, err := registrations.Push(5, "mined")
+	assert.Nil(t, err)
+	lost, err := registrations.Push(10, "lost")
+	assert.Nil(t, err)
+	sent := map[uint64]confirm.Tx{
+		mined.Seq: confirm.NewTx("test-register", mined.Data),
+		lost.Seq:  confirm.NewTx("test-register", lost.Data),
+	}
+
+	//the registration for 5 was mined, the one for 10 was not before the contract moved on to 20
+	window := schedule.Window{Start: 20, Batch: 0, RegisteredHeight: 5}
+	err = sendQueuedRegistrations(context.Background(), nil, registrations, sent, nil, 20, window, Config{})
+	assert.Nil(t, err)
+	assert.Empty(t, sent)
+	assert.Equal(t, 0, registrations.Len())
+	assert.Equal(t, int64(1), metrics.GetOrRegisterCounter("tx.test-register.confirmed", nil).Count())
+	assert.Equal(t, int64(1), metrics.GetOrRegisterCounter("tx.test-register.timed-out", nil).Count())
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/dappley/iot-security/budget"
+	"github.com/dappley/iot-security/config"
+	"github.com/dappley/iot-security/confirm"
+	"github.com/dappley/iot-security/contract/iotsecurity"
+	"github.com/dappley/iot-security/errs"
+	"github.com/dappley/iot-security/keystore"
+	"github.com/dappley/iot-security/retry"
+	"github.com/dappley/iot-security/signer"
+	logger "github.com/sirupsen/logrus"
+	"strings"
+)
+
+type AdminConfig = config.Admin
+
+const defaultMaxAttempts = 5
+
+//setupCommands change some of the node addresses of the contract, instead of setting all of them
+var setupCommands = map[string]command{
+	"add":     runSetupAdd,
+	"remove":  runSetupRemove,
+	"replace": runSetupReplace,
+}
+
+//runSetup sets the node addresses of the contract and waits until the contract storage holds them
+func runSetup(args []string) int {
+	if len(args) > 0 {
+		if run, ok := setupCommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+
+	flags := newFlagSet("setup", "[-f file] [flags]\n       iotsec setup add|remove [-f file] [flags] <address>...\n       iotsec setup replace [-f file] [flags] <old address> <new address>")
+	configs := newAdminSource(flags)
+	flags.Parse(args)
+
+	config, err := configs.loadContract()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+	contractClient := iotsecurity.NewPoolClient(pool, config.ContractAddr)
+	if err := initialSetup(contractClient, config); err != nil {
+		logger.Error("Setup failed. Error:", err)
+		return 1
+	}
+	logger.Info("Setup is confirmed!")
+	return 0
+}
+
+//initialSetup sends the setup and waits until the contract storage holds the addresses
+func initialSetup(contractClient *iotsecurity.Client, config AdminConfig) error {
+
+	adminSigner, err := initAdminSigner(config)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := checkAdmin(ctx, contractClient, adminSigner); err != nil {
+		return err
+	}
+	nonce, err := contractClient.MembershipNonce(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := iotsecurity.Setup(config.Addresses, nonce, adminSigner)
+	if err != nil {
+		return err
+	}
+	return sendAdminCall(contractClient, config, call, membershipChanged(contractClient, nonce, config.Addresses))
+}
+
+//checkAdmin checks that the admin signer is the admin of the contract, if the contract stores its admin
+func checkAdmin(ctx context.Context, contractClient *iotsecurity.Client, adminSigner signer.Signer) error {
+	admin, err := contractClient.Admin(ctx)
+	if err != nil {
+		return err
+	}
+	if admin != "" && admin != adminSigner.Address() {
+		return errs.Key("check admin key", fmt.Errorf("the admin of the contract is %s, not the signer address %s", admin, adminSigner.Address()))
+	}
+	return nil
+}
+
+//membershipChanged confirms a call signed with nonce once the contract has counted a membership change past it
+//and its node addresses are the expected ones. The addresses alone may already be the expected ones before the call is mined.
+func membershipChanged(contractClient *iotsecurity.Client, nonce uint64, expected []string) confirm.Check {
+	return func(ctx context.Context) (bool, error) {
+		current, err := contractClient.MembershipNonce(ctx)
+		if err != nil || current <= nonce {
+			return false, err
+		}
+		addrs, err := contractClient.NodeAddresses(ctx)
+		return strings.Join(addrs, ",") == strings.Join(expected, ","), err
+	}
+}
+
+//sendAdminCall sends a call signed by the admin and waits until confirmed reports that the contract storage holds its change
+func sendAdminCall(contractClient *iotsecurity.Client, config AdminConfig, call iotsecurity.Call, confirmed confirm.Check) error {
+	data, err := call.Data()
+	if err != nil {
+		return errs.Config("encode function", err)
+	}
+
+	//setup is a one-off command, so it gives up eventually instead of retrying forever
+	policy := retry.NewPolicy(config.Retry)
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+	sender := iotsecurity.Sender{From: config.SenderAddr, Fee: budget.NewFee(config.Amount, config.Tip)}
+	err = retry.Do(policy, func() error {
+		err := contractClient.Send(context.Background(), call, sender)
+		if err != nil {
+			logger.Warn("RPC Send failed. err:", err)
+		}
+		return err
+	})
+	if err != nil {
+		confirm.RecordRejected(call.Function, data, err.Error())
+		return err
+	}
+
+	outcome := confirm.Wait(context.Background(), confirm.NewTx(call.Function, data), confirmed, config.Confirm)
+	if outcome != confirm.Confirmed {
+		return errs.Rejected("confirm "+call.Function, fmt.Errorf("the %s is %s", call.Function, outcome))
+	}
+	return nil
+}
+
+//nodeChange is a change of some node addresses of the contract by setup add, remove or replace
+type nodeChange struct {
+	//apply returns the node addresses after the change, the way the contract changes them
+	apply func(nodes []string) ([]string, error)
+	//call signs the change with the membership nonce of the contract
+	call func(nonce uint64, admin signer.Signer) (iotsecurity.Call, error)
+}
+
+func runSetupAdd(args []string) int {
+	return runNodeChange("setup add", "<address>...", args, 0, func(addrs []string) nodeChange {
+		return nodeChange{
+			apply: func(nodes []string) ([]string, error) {
+				return iotsecurity.WithNodes(nodes, addrs)
+			},
+			call: func(nonce uint64, admin signer.Signer) (iotsecurity.Call, error) {
+				return iotsecurity.AddNodes(addrs, nonce, admin)
+			},
+		}
+	})
+}
+
+func runSetupRemove(args []string) int {
+	return runNodeChange("setup remove", "<address>...", args, 0, func(addrs []string) nodeChange {
+		return nodeChange{
+			apply: func(nodes []string) ([]string, error) {
+				return iotsecurity.WithoutNodes(nodes, addrs)
+			},
+			call: func(nonce uint64, admin signer.Signer) (iotsecurity.Call, error) {
+				return iotsecurity.RemoveNodes(addrs, nonce, admin)
+			},
+		}
+	})
+}
+
+func runSetupReplace(args []string) int {
+	return runNodeChange("setup replace", "<old address> <new address>", args, 2, func(addrs []string) nodeChange {
+		return nodeChange{
+			apply: func(nodes []string) ([]string, error) {
+				return iotsecurity.WithNodeReplaced(nodes, addrs[0], addrs[1])
+			},
+			call: func(nonce uint64, admin signer.Signer) (iotsecurity.Call, error) {
+				return iotsecurity.ReplaceNode(addrs[0], addrs[1], nonce, admin)
+			},
+		}
+	})
+}
+
+//runNodeChange parses the addresses of setup add, remove or replace, sends the change and waits until the contract storage holds it.
+//nArgs is the number of addresses the command takes, or 0 for any number.
+func runNodeChange(name string, argsUsage string, args []string, nArgs int, newChange func(addrs []string) nodeChange) int {
+	flags := newFlagSet(name, "[-f file] [flags] "+argsUsage)
+	configs := newAdminSource(flags)
+	flags.Parse(args)
+
+	addrs := flags.Args()
+	if len(addrs) == 0 || nArgs > 0 && len(addrs) != nArgs {
+		flags.Usage()
+		return 2
+	}
+	for _, addr := range addrs {
+		if err := config.CheckUserAddress(addr); err != nil {
+			logger.Errorf("invalid address %s. Error: %v", addr, err)
+			return 2
+		}
+	}
+
+	config, err := configs.loadContract()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+	contractClient := iotsecurity.NewPoolClient(pool, config.ContractAddr)
+	if err := changeNodes(contractClient, config, newChange(addrs)); err != nil {
+		logger.Errorf("%s failed. Error: %v", name, err)
+		return 1
+	}
+	logger.Infof("%s is confirmed!", name)
+	if configs.filePath != "" {
+		logger.Warnf("The addresses in %s are not changed. Update them before the next full setup, which sets all the node addresses again.", configs.filePath)
+	}
+	return 0
+}
+
+//changeNodes checks the change against the node addresses of the contract, signs it with the membership nonce and sends it
+func changeNodes(contractClient *iotsecurity.Client, config AdminConfig, change nodeChange) error {
+	ctx := context.Background()
+	adminSigner, err := initAdminSigner(config)
+	if err != nil {
+		return err
+	}
+	if err := checkAdmin(ctx, contractClient, adminSigner); err != nil {
+		return err
+	}
+	nodes, err := contractClient.NodeAddresses(ctx)
+	if err != nil {
+		return err
+	}
+	expected, err := change.apply(nodes)
+	if err != nil {
+		return errs.Config("check addresses", err)
+	}
+	nonce, err := contractClient.MembershipNonce(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := change.call(nonce, adminSigner)
+	if err != nil {
+		return err
+	}
+	return sendAdminCall(contractClient, config, call, membershipChanged(contractClient, nonce, expected))
+}
+
+//initAdminSigner creates the admin signer and checks it against adminPubKey if the config still has one
+func initAdminSigner(config AdminConfig) (signer.Signer, error) {
+	adminSigner, err := signer.New(config.Signer, func() ([]byte, error) {
+		return loadAdminKey(config)
+	})
+	if err != nil {
+		return nil, errs.Key("create admin signer", err)
+	}
+	pubKey := hex.EncodeToString(adminSigner.PublicKey())
+	if config.AdminPubKey != "" && config.AdminPubKey != pubKey {
+		return nil, errs.Key("check admin key", fmt.Errorf("adminPubKey does not match the signer public key %s", pubKey))
+	}
+	return adminSigner, nil
+}
+
+//loadAdminKey decrypts the admin private key from its keystore. Plaintext keys in the config are deprecated.
+func loadAdminKey(config AdminConfig) ([]byte, error) {
+	if config.AdminKeystore != "" {
+		privKey, err := keystore.LoadKey(config.AdminKeystore, config.PassphraseFile)
+		if err != nil {
+			return nil, errs.Key("load keystore "+config.AdminKeystore, err)
+		}
+		return privKey, nil
+	}
+	logger.Warn("The admin private key is stored in plaintext. Use iotsec keys migrate to move it into an encrypted keystore.")
+	privKey, err := hex.DecodeString(config.AdminPrivKey)
+	if err != nil {
+		return nil, errs.Key("decode admin private key", err)
+	}
+	return privKey, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dappley/go-dappley/rpc/pb"
+	"github.com/dappley/iot-security/queue"
+	"github.com/dappley/iot-security/rpcclient"
+	"github.com/dappley/iot-security/schedule"
+	logger "github.com/sirupsen/logrus"
+	"path/filepath"
+)
+
+//runStatus prints what the monitor of a device sees: its node, the sender's balance, its registration window and its queued registrations
+func runStatus(args []string) int {
+	flags := newFlagSet("status", "[-f file] [-common file] [flags]")
+	configs := newConfigSource(flags)
+	flags.Parse(args)
+	configs.resolvePaths()
+
+	config, commonConfig, err := configs.load()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+	//the key is only loaded if the config does not name the node address
+	if config.NodeAddr == "" {
+		if _, err := initSigner(&config); err != nil {
+			logger.Fatal("can not load node key. Error:", err)
+		}
+	}
+
+	registrations, err := queue.Open(filepath.Join(getStateDir(config), "queue"))
+	if err != nil {
+		logger.Fatal("can not open registration queue. Error:", err)
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	rpcService := rpcpb.NewRpcServiceClient(pool.Conn())
+	blkHeight, err := getBlockHeight(ctx, rpcService)
+	if err != nil {
+		logger.Error("can not read the block height. Error:", err)
+		return 1
+	}
+	balance, err := rpcService.RpcGetBalance(ctx, &rpcpb.GetBalanceRequest{
+		Address: config.SenderAddr,
+	})
+	if err != nil {
+		logger.Error("can not read the balance. Error:", err)
+		return 1
+	}
+	window, err := schedule.Read(ctx, rpcclient.ContractQuery(pool.Conn(), commonConfig.ContractAddr), config.NodeAddr)
+	if err != nil {
+		logger.Error("can not read the contract. Error:", err)
+		return 1
+	}
+
+	contractStatus := "verified"
+	release, err := checkContract(ctx, pool.Conn(), commonConfig.ContractAddr, commonConfig.ContractSha256)
+	if err != nil {
+		contractStatus = err.Error()
+	}
+
+	fmt.Println("node:                ", config.NodeAddr)
+	fmt.Println("endpoint:            ", pool.Endpoint())
+	fmt.Println("block height:        ", blkHeight)
+	fmt.Println("contract:            ", commonConfig.ContractAddr)
+	fmt.Println("contract version:    ", release.Version, "("+contractStatus+")")
+	fmt.Println("sender:              ", config.SenderAddr)
+	fmt.Println("balance:             ", balance.Amount)
+	fmt.Println("target cycle:        ", window.Start)
+	if window.Batch >= 0 {
+		fmt.Println("target batch:        ", window.Batch)
+		fmt.Println("batch checked at:    ", window.Deadline())
+	}
+	fmt.Println("registered:          ", window.Registered)
+	if reason := window.Reason(blkHeight); reason != "" {
+		fmt.Println("accepts registration: no,", reason)
+	} else {
+		fmt.Println("accepts registration: yes")
+	}
+	fmt.Println("queued registrations:", registrations.Len())
+	return 0
+}
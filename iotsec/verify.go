@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dappley/iot-security/contract/iotsecurity"
+	logger "github.com/sirupsen/logrus"
+)
+
+//runVerify checks the registrations of the given nodes, or of all nodes set up in the contract, as the contract does when it verifies them.
+//It returns 1 if any node fails.
+func runVerify(args []string) int {
+	flags := newFlagSet("verify", "[-f file] [flags] [address ...]")
+	configs := newAdminSource(flags)
+	flags.Parse(args)
+
+	config, err := configs.loadContract()
+	if err != nil {
+		logger.Fatal("can not read config file. Error:", err)
+	}
+
+	pool, err := config.Connection().Dial()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer pool.Close()
+	contractClient := iotsecurity.NewPoolClient(pool, config.ContractAddr)
+
+	addrs := flags.Args()
+	if len(addrs) == 0 {
+		if addrs, err = contractClient.NodeAddresses(context.Background()); err != nil {
+			logger.Error("can not read the node addresses. Error:", err)
+			return 1
+		}
+		if len(addrs) == 0 {
+			logger.Error("The contract has not been set up")
+			return 1
+		}
+	}
+
+	code := 0
+	for _, addr := range addrs {
+		reason, err := contractClient.Check(context.Background(), addr)
+		if err != nil {
+			logger.WithFields(logger.Fields{
+				"addr": addr,
+			}).Error("Verification failed. Error:", err)
+			code = 1
+			continue
+		}
+		if reason != "" {
+			fmt.Printf("%s: failed, %s\n", addr, reason)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s: ok\n", addr)
+	}
+	return code
+}
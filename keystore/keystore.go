@@ -0,0 +1,175 @@
+// Package keystore stores secp256k1 private keys encrypted with a passphrase.
+//
+// The passphrase is stretched with scrypt into a 256-bit key and the private key
+// is sealed with AES-GCM. The address of the key is authenticated as additional
+// data, so a keystore file cannot be relabelled without detection.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	version    = 1
+	cipherName = "aes-256-gcm"
+	kdfName    = "scrypt"
+	keyLen     = 32
+	saltLen    = 32
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted keystore")
+
+type ScryptParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+// DefaultScryptParams costs about 32MB of memory, which still fits on small devices.
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+type CryptoParams struct {
+	Cipher     string       `json:"cipher"`
+	Kdf        string       `json:"kdf"`
+	KdfParams  ScryptParams `json:"kdfParams"`
+	Nonce      string       `json:"nonce"`
+	Ciphertext string       `json:"ciphertext"`
+}
+
+type KeyFile struct {
+	Version   int          `json:"version"`
+	Address   string       `json:"address"`
+	PublicKey string       `json:"publicKey"`
+	Crypto    CryptoParams `json:"crypto"`
+}
+
+// Encrypt seals privKey with passphrase. The address and public key are stored in clear.
+func Encrypt(privKey []byte, address string, pubKey string, passphrase []byte, params ScryptParams) (*KeyFile, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params.Salt = hex.EncodeToString(salt)
+
+	gcm, err := newGCM(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &KeyFile{
+		Version:   version,
+		Address:   address,
+		PublicKey: pubKey,
+		Crypto: CryptoParams{
+			Cipher:     cipherName,
+			Kdf:        kdfName,
+			KdfParams:  params,
+			Nonce:      hex.EncodeToString(nonce),
+			Ciphertext: hex.EncodeToString(gcm.Seal(nil, nonce, privKey, []byte(address))),
+		},
+	}, nil
+}
+
+func (k *KeyFile) Decrypt(passphrase []byte) ([]byte, error) {
+	if k.Version != version || k.Crypto.Cipher != cipherName || k.Crypto.Kdf != kdfName {
+		return nil, fmt.Errorf("unsupported keystore version %d (%s, %s)", k.Version, k.Crypto.Cipher, k.Crypto.Kdf)
+	}
+	nonce, err := hex.DecodeString(k.Crypto.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(k.Crypto.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, k.Crypto.KdfParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	privKey, err := gcm.Open(nil, nonce, ciphertext, []byte(k.Address))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return privKey, nil
+}
+
+func newGCM(passphrase []byte, params ScryptParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(passphrase, salt, params.N, params.R, params.P, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func Load(path string) (*KeyFile, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	k := &KeyFile{}
+	if err := json.Unmarshal(raw, k); err != nil {
+		return nil, fmt.Errorf("%s is not a keystore file: %v", path, err)
+	}
+	return k, nil
+}
+
+// Save writes the keystore file readable by its owner only. An existing file is never overwritten.
+func (k *KeyFile) Save(path string) error {
+	raw, err := json.MarshalIndent(k, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(raw); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+// LoadKey decrypts the keystore at path with the passphrase from ReadPassphrase.
+func LoadKey(path string, passphraseFile string) ([]byte, error) {
+	k, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := ReadPassphrase(passphraseFile, false)
+	if err != nil {
+		return nil, err
+	}
+	return k.Decrypt(passphrase)
+}
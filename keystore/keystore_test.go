@@ -0,0 +1,65 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testParams = ScryptParams{N: 1 << 10, R: 8, P: 1}
+
+func TestKeyFile_EncryptDecrypt(t *testing.T) {
+	privKey, _ := hex.DecodeString("f22bac4a73a9881d523075d9bb749ca537c7fa451366d935bcb65509968ac3e4")
+	k, err := Encrypt(privKey, "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP", "fd26", []byte("secret"), testParams)
+	assert.Nil(t, err)
+	assert.NotContains(t, k.Crypto.Ciphertext, hex.EncodeToString(privKey))
+
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "node.key")
+	assert.Nil(t, k.Save(path))
+	//never overwrite an existing key
+	assert.NotNil(t, k.Save(path))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := Load(path)
+	assert.Nil(t, err)
+	decrypted, err := loaded.Decrypt([]byte("secret"))
+	assert.Nil(t, err)
+	assert.Equal(t, privKey, decrypted)
+
+	_, err = loaded.Decrypt([]byte("wrong"))
+	assert.Equal(t, ErrWrongPassphrase, err)
+
+	//the address is authenticated
+	loaded.Address = "dKVPqHKEz2vSLg1w8dCuta61mkyej2CFB1"
+	_, err = loaded.Decrypt([]byte("secret"))
+	assert.Equal(t, ErrWrongPassphrase, err)
+}
+
+func TestReadPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	passphraseFile := filepath.Join(dir, "passphrase")
+	assert.Nil(t, ioutil.WriteFile(passphraseFile, []byte("from file\n"), 0600))
+
+	os.Unsetenv(PassphraseEnv)
+	passphrase, err := ReadPassphrase(passphraseFile, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "from file", string(passphrase))
+
+	os.Setenv(PassphraseEnv, "from env")
+	defer os.Unsetenv(PassphraseEnv)
+	passphrase, err = ReadPassphrase(passphraseFile, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "from env", string(passphrase))
+}
@@ -0,0 +1,57 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const PassphraseEnv = "IOTSEC_KEYSTORE_PASSPHRASE"
+
+// ReadPassphrase returns the keystore passphrase from, in order, the IOTSEC_KEYSTORE_PASSPHRASE
+// environment variable, passphraseFile if set, or a prompt on the terminal.
+// A prompted passphrase is asked twice if confirm is set.
+func ReadPassphrase(passphraseFile string, confirm bool) ([]byte, error) {
+	if passphrase, ok := os.LookupEnv(PassphraseEnv); ok {
+		return []byte(passphrase), nil
+	}
+	if passphraseFile != "" {
+		raw, err := ioutil.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		//editors usually leave a trailing newline
+		return bytes.TrimRight(raw, "\r\n"), nil
+	}
+	return promptPassphrase(confirm)
+}
+
+func promptPassphrase(confirm bool) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, errors.New("no keystore passphrase: set " + PassphraseEnv + ", a passphrase file or run on a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Keystore passphrase: ")
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if !confirm {
+		return passphrase, nil
+	}
+	fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+	repeated, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, repeated) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,238 @@
+// Package measure computes deterministic integrity measurements of a file tree.
+//
+// Every file and directory under a root becomes a leaf describing its relative
+// path, content hash, mode, owner and size. Leaves are ordered lexically by path
+// and folded into a Merkle root, so the same tree always yields the same root and
+// any change to a single file changes it.
+package measure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+type Leaf struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+	Mode string `json:"mode"`
+	Uid  uint32 `json:"uid"`
+	Gid  uint32 `json:"gid"`
+	Size int64  `json:"size"`
+}
+
+type Measurement struct {
+	Root   string `json:"root"`
+	Leaves []Leaf `json:"leaves"`
+}
+
+type Change struct {
+	Path   string
+	Kind   string
+	Fields []string
+}
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Measure walks root and returns the Merkle root over all entries beneath it.
+// Symbolic links are not followed; their target path is hashed instead.
+func Measure(root string) (*Measurement, error) {
+	w := newWalker(Policy{Path: root}, "", nil)
+	if err := w.walkRoot(); err != nil {
+		return nil, err
+	}
+	return NewMeasurement(w.leaves), nil
+}
+
+// NewLeaf describes the entry at path, recording it under the relative name rel.
+func NewLeaf(path string, rel string, info os.FileInfo) (Leaf, error) {
+	return newLeaf(path, rel, info, -1)
+}
+
+// newLeaf hashes at most limit bytes of a regular file, or all of it if limit is negative
+func newLeaf(path string, rel string, info os.FileInfo, limit int64) (Leaf, error) {
+	leaf := Leaf{
+		Path: rel,
+		Mode: info.Mode().String(),
+		Size: info.Size(),
+	}
+	leaf.Uid, leaf.Gid = owner(info)
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return Leaf{}, err
+		}
+		sum := sha256.Sum256([]byte(target))
+		leaf.Hash = hex.EncodeToString(sum[:])
+	case info.Mode().IsRegular():
+		hash, err := hashFile(path, limit)
+		if err != nil {
+			return Leaf{}, err
+		}
+		leaf.Hash = hash
+		if limit >= 0 && limit < leaf.Size {
+			leaf.Size = limit
+		}
+	default:
+		//directories and special files have no content to hash
+		leaf.Size = 0
+	}
+	return leaf, nil
+}
+
+// NewMeasurement sorts the leaves by path and computes their Merkle root. Leaves with the same
+// path are ordered by their digest, so that the root does not depend on the order they are given in.
+func NewMeasurement(leaves []Leaf) *Measurement {
+	sort.SliceStable(leaves, func(i, j int) bool {
+		if leaves[i].Path != leaves[j].Path {
+			return leaves[i].Path < leaves[j].Path
+		}
+		return bytes.Compare(leaves[i].digest(), leaves[j].digest()) < 0
+	})
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = leaf.digest()
+	}
+	return &Measurement{
+		Root:   hex.EncodeToString(merkleRoot(level)),
+		Leaves: leaves,
+	}
+}
+
+func (l Leaf) digest() []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%d\x00%d", l.Path, l.Hash, l.Mode, l.Uid, l.Gid, l.Size)
+	return h.Sum(nil)
+}
+
+func merkleRoot(level [][]byte) []byte {
+	if len(level) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				//an unpaired node is promoted to the next level unchanged
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write([]byte{nodePrefix})
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+func hashFile(path string, limit int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if limit >= 0 {
+		r = io.LimitReader(file, limit)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Save writes the measurement with its leaf list to path, replacing any previous file atomically.
+func (m *Measurement) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func Load(path string) (*Measurement, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Measurement{}
+	if err := json.Unmarshal(raw, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Diff lists the entries that differ between two measurements, ordered by path.
+func Diff(prev, curr *Measurement) []Change {
+	prevLeaves := make(map[string]Leaf, len(prev.Leaves))
+	for _, leaf := range prev.Leaves {
+		prevLeaves[leaf.Path] = leaf
+	}
+
+	var changes []Change
+	for _, leaf := range curr.Leaves {
+		old, ok := prevLeaves[leaf.Path]
+		if !ok {
+			changes = append(changes, Change{Path: leaf.Path, Kind: ChangeAdded})
+			continue
+		}
+		delete(prevLeaves, leaf.Path)
+		if fields := diffFields(old, leaf); len(fields) > 0 {
+			changes = append(changes, Change{Path: leaf.Path, Kind: ChangeModified, Fields: fields})
+		}
+	}
+	for path := range prevLeaves {
+		changes = append(changes, Change{Path: path, Kind: ChangeRemoved})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func diffFields(old, curr Leaf) []string {
+	var fields []string
+	if old.Hash != curr.Hash {
+		fields = append(fields, "content")
+	}
+	if old.Mode != curr.Mode {
+		fields = append(fields, "mode")
+	}
+	if old.Uid != curr.Uid || old.Gid != curr.Gid {
+		fields = append(fields, "owner")
+	}
+	if old.Size != curr.Size {
+		fields = append(fields, "size")
+	}
+	return fields
+}
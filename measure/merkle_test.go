@@ -0,0 +1,178 @@
+package measure
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTree(t *testing.T) string {
+	root, err := ioutil.TempDir("", "measure")
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "etc", "app"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc", "app", "app.conf"), []byte("port=80"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "bin"), []byte("#!/bin/sh"), 0755))
+	return root
+}
+
+func TestMeasure_Deterministic(t *testing.T) {
+	root := makeTree(t)
+	defer os.RemoveAll(root)
+
+	m1, err := Measure(root)
+	assert.Nil(t, err)
+	m2, err := Measure(root)
+	assert.Nil(t, err)
+
+	assert.Equal(t, m1.Root, m2.Root)
+	assert.Equal(t, []string{".", "bin", "etc", "etc/app", "etc/app/app.conf"}, leafPaths(m1))
+}
+
+func TestMeasure_DetectsChanges(t *testing.T) {
+	root := makeTree(t)
+	defer os.RemoveAll(root)
+
+	before, err := Measure(root)
+	assert.Nil(t, err)
+
+	//same size, different content
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc", "app", "app.conf"), []byte("port=81"), 0644))
+	assert.Nil(t, os.Chmod(filepath.Join(root, "bin"), 0777))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc", "extra"), []byte{}, 0644))
+
+	after, err := Measure(root)
+	assert.Nil(t, err)
+	assert.NotEqual(t, before.Root, after.Root)
+
+	changes := Diff(before, after)
+	assert.Equal(t, []Change{
+		{Path: "bin", Kind: ChangeModified, Fields: []string{"mode"}},
+		{Path: "etc/app/app.conf", Kind: ChangeModified, Fields: []string{"content"}},
+		{Path: "etc/extra", Kind: ChangeAdded},
+	}, changes)
+}
+
+func TestMeasurement_SaveLoad(t *testing.T) {
+	root := makeTree(t)
+	defer os.RemoveAll(root)
+
+	m, err := Measure(root)
+	assert.Nil(t, err)
+
+	path := filepath.Join(root, "..", filepath.Base(root)+".leaves.json")
+	defer os.Remove(path)
+	assert.Nil(t, m.Save(path))
+
+	loaded, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, m, loaded)
+	assert.Empty(t, Diff(m, loaded))
+}
+
+func TestMerkleRoot_Empty(t *testing.T) {
+	assert.Equal(t,
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		NewMeasurement(nil).Root,
+	)
+}
+
+func leafPaths(m *Measurement) []string {
+	var paths []string
+	for _, leaf := range m.Leaves {
+		paths = append(paths, leaf.Path)
+	}
+	return paths
+}
+
+func TestMeasurePolicies(t *testing.T) {
+	root := makeTree(t)
+	defer os.RemoveAll(root)
+	log := filepath.Join(root, "log")
+	assert.Nil(t, os.MkdirAll(log, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(log, "app.log"), []byte("line1\n"), 0644))
+
+	policies := []Policy{
+		{Path: filepath.Join(root, "etc"), Include: []string{"*.conf"}},
+		{Path: log, Change: PolicyAppendOnly},
+		{Path: filepath.Join(root, "bin"), Change: PolicyMutable},
+	}
+	baseline, err := MeasurePolicies(policies, nil)
+	assert.Nil(t, err)
+	prefix := filepath.ToSlash(root)
+	assert.Equal(t, []string{
+		prefix + "/bin",
+		prefix + "/etc",
+		prefix + "/etc/app/app.conf",
+		prefix + "/log",
+		prefix + "/log/app.log",
+	}, leafPaths(baseline))
+
+	//appending to the log, adding a new log and rewriting a mutable file keep the root
+	f, err := os.OpenFile(filepath.Join(log, "app.log"), os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteString("line2\n")
+	assert.Nil(t, err)
+	f.Close()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(log, "app.log.1"), []byte("rotated"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "bin"), []byte("#!/bin/bash"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "etc", "notes.txt"), []byte("ignored"), 0644))
+
+	m, err := MeasurePolicies(policies, baseline)
+	assert.Nil(t, err)
+	assert.Equal(t, baseline.Root, m.Root)
+
+	//rewriting the existing part of the log changes the root
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(log, "app.log"), []byte("LINE1\nline2\n"), 0644))
+	m, err = MeasurePolicies(policies, baseline)
+	assert.Nil(t, err)
+	assert.NotEqual(t, baseline.Root, m.Root)
+	assert.Equal(t, []Change{
+		{Path: prefix + "/log/app.log", Kind: ChangeModified, Fields: []string{"content"}},
+	}, Diff(baseline, m))
+}
+
+func TestMeasurePolicies_Overlapping(t *testing.T) {
+	root := makeTree(t)
+	defer os.RemoveAll(root)
+
+	etc := filepath.Join(root, "etc")
+	for _, policies := range [][]Policy{
+		{{Path: etc}, {Path: filepath.Join(etc, "app")}},
+		{{Path: filepath.Join(etc, "app")}, {Path: etc + "/"}},
+		{{Path: etc}, {Path: etc, Change: PolicyMutable}},
+	} {
+		_, err := MeasurePolicies(policies, nil)
+		assert.NotNil(t, err)
+	}
+
+	assert.True(t, Policy{Path: "/"}.Overlaps(Policy{Path: "/etc"}))
+	assert.True(t, Policy{Path: "."}.Overlaps(Policy{Path: "etc"}))
+	assert.False(t, Policy{Path: "/etc"}.Overlaps(Policy{Path: "/etc2"}))
+	assert.False(t, Policy{Path: "."}.Overlaps(Policy{Path: "../etc"}))
+}
+
+func TestNewMeasurement_SamePath(t *testing.T) {
+	a := Leaf{Path: "/etc/passwd", Hash: "aa", Mode: "-rw-r--r--"}
+	b := Leaf{Path: "/etc/passwd", Mode: "-rw-r--r--"}
+	c := Leaf{Path: "/etc", Mode: "drwxr-xr-x"}
+	m1 := NewMeasurement([]Leaf{a, b, c})
+	m2 := NewMeasurement([]Leaf{b, c, a})
+	assert.Equal(t, m1.Root, m2.Root)
+	assert.Equal(t, m1.Leaves, m2.Leaves)
+}
+
+func TestMeasurePolicies_FollowSymlinks(t *testing.T) {
+	root := makeTree(t)
+	defer os.RemoveAll(root)
+	assert.Nil(t, os.Symlink(filepath.Join(root, "etc"), filepath.Join(root, "etc", "app", "loop")))
+
+	m, err := MeasurePolicies([]Policy{{Path: root, FollowSymlinks: true}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(m.Leaves))
+
+	assert.NotNil(t, Policy{Path: root, Change: "sometimes"}.Validate())
+	assert.NotNil(t, Policy{Path: root, Exclude: []string{"[a"}}.Validate())
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package measure
+
+import (
+	"os"
+	"syscall"
+)
+
+func owner(info os.FileInfo) (uint32, uint32) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Uid, stat.Gid
+	}
+	return 0, 0
+}
@@ -0,0 +1,246 @@
+package measure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Change semantics of a monitored root.
+const (
+	// Every byte, mode bit and owner of every entry is measured.
+	PolicyImmutable = "immutable"
+	// Files present in the baseline may grow but their existing content may not change.
+	// Files created after the baseline are ignored.
+	PolicyAppendOnly = "append-only"
+	// Entries may change content freely; only their presence, mode and owner are measured.
+	PolicyMutable = "mutable"
+)
+
+// Policy describes how one monitored root is measured.
+// Include and Exclude are path.Match globs matched against both the path relative
+// to the root and the entry's base name. An excluded directory is skipped entirely.
+type Policy struct {
+	Path           string   `json:"path"`
+	Include        []string `json:"include"`
+	Exclude        []string `json:"exclude"`
+	FollowSymlinks bool     `json:"followSymlinks"`
+	Change         string   `json:"change"`
+}
+
+func (p Policy) Validate() error {
+	if p.Path == "" {
+		return fmt.Errorf("monitored path is not set")
+	}
+	switch p.Change {
+	case "", PolicyImmutable, PolicyAppendOnly, PolicyMutable:
+	default:
+		return fmt.Errorf("%s: unknown change policy %q", p.Path, p.Change)
+	}
+	for _, pattern := range append(append([]string{}, p.Include...), p.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s: invalid pattern %q", p.Path, pattern)
+		}
+	}
+	return nil
+}
+
+// Overlaps reports whether p and other monitor the same entries, because their roots are the
+// same or one is inside the other. The leaves of such entries would be added twice.
+func (p Policy) Overlaps(other Policy) bool {
+	a, b := p.root(), other.root()
+	return a == b || contains(a, b) || contains(b, a)
+}
+
+func (p Policy) root() string {
+	return path.Clean(filepath.ToSlash(p.Path))
+}
+
+// contains reports whether the root dir holds the root file
+func contains(dir string, file string) bool {
+	switch dir {
+	case "/":
+		return path.IsAbs(file)
+	case ".":
+		return !path.IsAbs(file) && file != ".." && !strings.HasPrefix(file, "../")
+	}
+	return strings.HasPrefix(file, dir+"/")
+}
+
+func (p Policy) changeSemantic() string {
+	if p.Change == "" {
+		return PolicyImmutable
+	}
+	return p.Change
+}
+
+// MeasurePolicies measures every root under its policy and rolls all of them up into one Merkle root.
+// Leaf paths are prefixed with the root they belong to. The baseline is the previous
+// measurement, if any; append-only roots use it to know which prefix of each file is fixed.
+func MeasurePolicies(policies []Policy, baseline *Measurement) (*Measurement, error) {
+	var base map[string]Leaf
+	if baseline != nil {
+		base = make(map[string]Leaf, len(baseline.Leaves))
+		for _, leaf := range baseline.Leaves {
+			base[leaf.Path] = leaf
+		}
+	}
+
+	var leaves []Leaf
+	for i, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			return nil, err
+		}
+		for _, earlier := range policies[:i] {
+			if policy.Overlaps(earlier) {
+				return nil, fmt.Errorf("%s overlaps %s", policy.Path, earlier.Path)
+			}
+		}
+		w := newWalker(policy, policy.root(), base)
+		if err := w.walkRoot(); err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, w.leaves...)
+	}
+	return NewMeasurement(leaves), nil
+}
+
+type walker struct {
+	policy  Policy
+	prefix  string
+	base    map[string]Leaf
+	visited map[string]bool
+	leaves  []Leaf
+}
+
+func newWalker(policy Policy, prefix string, base map[string]Leaf) *walker {
+	w := &walker{
+		policy:  policy,
+		prefix:  prefix,
+		visited: make(map[string]bool),
+	}
+	//a root that is not in the baseline yet is measured in full
+	if _, ok := base[w.name(".")]; ok {
+		w.base = base
+	}
+	return w
+}
+
+func (w *walker) name(rel string) string {
+	if w.prefix == "" {
+		return rel
+	}
+	return path.Join(w.prefix, rel)
+}
+
+func (w *walker) walkRoot() error {
+	info, err := os.Lstat(w.policy.Path)
+	if err != nil {
+		return err
+	}
+	return w.walk(w.policy.Path, ".", info)
+}
+
+func (w *walker) walk(file string, rel string, info os.FileInfo) error {
+	if rel != "." && matches(w.policy.Exclude, rel) {
+		return nil
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 && w.policy.FollowSymlinks {
+		//dangling links are recorded as links
+		if target, err := os.Stat(file); err == nil {
+			info = target
+		}
+	}
+
+	if !info.IsDir() {
+		if len(w.policy.Include) > 0 && !matches(w.policy.Include, rel) {
+			return nil
+		}
+		return w.addLeaf(file, rel, info)
+	}
+
+	if w.policy.FollowSymlinks {
+		real, err := filepath.EvalSymlinks(file)
+		if err != nil {
+			return err
+		}
+		//symlinks pointing back up the tree would otherwise never terminate,
+		//so a link to a directory that was already walked is recorded as a link
+		if w.visited[real] {
+			link, err := os.Lstat(file)
+			if err != nil {
+				return err
+			}
+			return w.addLeaf(file, rel, link)
+		}
+		w.visited[real] = true
+	}
+
+	if len(w.policy.Include) == 0 || rel == "." {
+		if err := w.addLeaf(file, rel, info); err != nil {
+			return err
+		}
+	}
+
+	entries, err := ioutil.ReadDir(file)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err := w.walk(filepath.Join(file, entry.Name()), path.Join(rel, entry.Name()), entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) addLeaf(file string, rel string, info os.FileInfo) error {
+	name := w.name(rel)
+	limit := int64(-1)
+
+	if info.Mode().IsRegular() {
+		switch w.policy.changeSemantic() {
+		case PolicyMutable:
+			leaf := Leaf{Path: name, Mode: info.Mode().String()}
+			leaf.Uid, leaf.Gid = owner(info)
+			w.leaves = append(w.leaves, leaf)
+			return nil
+		case PolicyAppendOnly:
+			if w.base != nil {
+				base, ok := w.base[name]
+				if !ok {
+					//created after the baseline
+					return nil
+				}
+				//a file shorter than its baseline is hashed in full, which will not match
+				if info.Size() >= base.Size {
+					limit = base.Size
+				}
+			}
+		}
+	}
+
+	leaf, err := newLeaf(file, name, info, limit)
+	if err != nil {
+		return err
+	}
+	w.leaves = append(w.leaves, leaf)
+	return nil
+}
+
+func matches(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
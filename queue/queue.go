@@ -0,0 +1,189 @@
+// Package queue persists signed registrations on disk until they are delivered to the node.
+//
+// Each entry is one JSON file named after its sequence number, written to a
+// temporary file and renamed into place, so a crash never leaves a partial entry
+// and entries are replayed in the order they were pushed. An entry that can not be
+// read anyway is moved aside with a .corrupt suffix, so it does not block the others.
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	entrySuffix   = ".json"
+	corruptSuffix = ".corrupt"
+)
+
+type Entry struct {
+	Seq       uint64    `json:"seq"`
+	BlkHeight uint64    `json:"blkHeight"`
+	Data      string    `json:"data"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Queue struct {
+	dir  string
+	next uint64
+	mu   sync.Mutex
+}
+
+// Open opens the queue stored in dir, creating the directory if needed.
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	q := &Queue{dir: dir, next: 1}
+	//quarantined entries keep their seq, so that a later entry quarantined with the same seq does not replace them
+	for _, suffix := range []string{entrySuffix, entrySuffix + corruptSuffix} {
+		seqs, err := q.seqsWithSuffix(suffix)
+		if err != nil {
+			return nil, err
+		}
+		if len(seqs) > 0 && seqs[len(seqs)-1] >= q.next {
+			q.next = seqs[len(seqs)-1] + 1
+		}
+	}
+	return q, nil
+}
+
+// Push appends a signed registration for the given block height.
+func (q *Queue) Push(blkHeight uint64, data string) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry := Entry{
+		Seq:       q.next,
+		BlkHeight: blkHeight,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	tmp, err := ioutil.TempFile(q.dir, "tmp-")
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return Entry{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return Entry{}, err
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), q.path(entry.Seq)); err != nil {
+		os.Remove(tmp.Name())
+		return Entry{}, err
+	}
+	q.next++
+	return entry, nil
+}
+
+// Entries returns all queued entries, oldest first. Entries that can not be read are
+// quarantined and skipped.
+func (q *Queue) Entries() ([]Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	seqs, err := q.seqs()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, seq := range seqs {
+		raw, err := ioutil.ReadFile(q.path(seq))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := Entry{}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			q.quarantine(seq, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// quarantine moves a corrupted entry aside, so that it is kept for inspection but no longer read
+func (q *Queue) quarantine(seq uint64, cause error) {
+	path := q.path(seq)
+	if err := os.Rename(path, path+corruptSuffix); err != nil {
+		logger.WithError(err).Errorf("Queue: can not quarantine the corrupted entry %s", path)
+		return
+	}
+	logger.WithError(cause).Errorf("Queue: the corrupted entry %s is moved to %s", path, path+corruptSuffix)
+}
+
+func (q *Queue) Remove(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := os.Remove(q.path(seq))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	seqs, err := q.seqs()
+	if err != nil {
+		return 0
+	}
+	return len(seqs)
+}
+
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, entrySuffix))
+}
+
+func (q *Queue) seqs() ([]uint64, error) {
+	return q.seqsWithSuffix(entrySuffix)
+}
+
+// seqsWithSuffix returns the seqs of the files named with suffix, in order
+func (q *Queue) seqsWithSuffix(suffix string) ([]uint64, error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
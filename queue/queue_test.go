@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue_PersistsInOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := Open(dir)
+	assert.Nil(t, err)
+	_, err = q.Push(5, "first")
+	assert.Nil(t, err)
+	second, err := q.Push(6, "second")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, q.Len())
+
+	//a reopened queue continues the sequence after the existing entries
+	q, err = Open(dir)
+	assert.Nil(t, err)
+	_, err = q.Push(7, "third")
+	assert.Nil(t, err)
+
+	entries, err := q.Entries()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "first", entries[0].Data)
+	assert.Equal(t, uint64(6), entries[1].BlkHeight)
+	assert.Equal(t, uint64(3), entries[2].Seq)
+
+	assert.Nil(t, q.Remove(second.Seq))
+	assert.Nil(t, q.Remove(second.Seq))
+	entries, err = q.Entries()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "third"}, []string{entries[0].Data, entries[1].Data})
+}
+
+func TestQueue_SkipsCorruptedEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := Open(dir)
+	assert.Nil(t, err)
+	first, err := q.Push(5, "first")
+	assert.Nil(t, err)
+	_, err = q.Push(6, "second")
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(q.path(first.Seq), []byte("{not json"), 0600))
+
+	entries, err := q.Entries()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "second", entries[0].Data)
+	}
+	//the corrupted entry is kept aside and no longer counted
+	_, err = os.Stat(q.path(first.Seq) + corruptSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, q.Len())
+
+	entries, err = q.Entries()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestQueue_DoesNotReuseQuarantinedSeqs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := Open(dir)
+	assert.Nil(t, err)
+	last, err := q.Push(5, "last")
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(q.path(last.Seq), []byte("{not json"), 0600))
+	_, err = q.Entries()
+	assert.Nil(t, err)
+
+	q, err = Open(dir)
+	assert.Nil(t, err)
+	next, err := q.Push(6, "next")
+	assert.Nil(t, err)
+	assert.Equal(t, last.Seq+1, next.Seq)
+	assert.Nil(t, ioutil.WriteFile(q.path(next.Seq), []byte("{not json either"), 0600))
+	_, err = q.Entries()
+	assert.Nil(t, err)
+
+	//both corrupted entries are kept
+	raw, err := ioutil.ReadFile(q.path(last.Seq) + corruptSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, "{not json", string(raw))
+	_, err = os.Stat(q.path(next.Seq) + corruptSuffix)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,88 @@
+// Package retry implements exponential backoff with jitter for recoverable errors.
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/dappley/iot-security/errs"
+)
+
+// Config is the JSON form of a Policy. Zero values fall back to DefaultPolicy.
+type Config struct {
+	InitialIntervalMs int64
+	MaxIntervalMs     int64
+	Multiplier        float64
+	Jitter            float64
+	MaxAttempts       int
+}
+
+type Policy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Jitter is the fraction of the interval that is randomized, between 0 and 1
+	Jitter float64
+	// MaxAttempts is the number of consecutive failures tolerated. 0 means retry forever
+	MaxAttempts int
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute * 2,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+func NewPolicy(config Config) Policy {
+	policy := DefaultPolicy()
+	if config.InitialIntervalMs > 0 {
+		policy.InitialInterval = time.Duration(config.InitialIntervalMs) * time.Millisecond
+	}
+	if config.MaxIntervalMs > 0 {
+		policy.MaxInterval = time.Duration(config.MaxIntervalMs) * time.Millisecond
+	}
+	if config.Multiplier >= 1 {
+		policy.Multiplier = config.Multiplier
+	}
+	if config.Jitter > 0 && config.Jitter <= 1 {
+		policy.Jitter = config.Jitter
+	}
+	if config.MaxAttempts > 0 {
+		policy.MaxAttempts = config.MaxAttempts
+	}
+	return policy
+}
+
+// Backoff returns the delay before the given retry attempt, counting from 1.
+func (p Policy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	interval := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt-1))
+	if interval > float64(p.MaxInterval) {
+		interval = float64(p.MaxInterval)
+	}
+	//spread the interval evenly over [1-jitter, 1+jitter] so a fleet does not retry in lockstep
+	interval *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(interval)
+}
+
+// Exhausted reports whether the given number of consecutive failures uses up the policy.
+func (p Policy) Exhausted(failures int) bool {
+	return p.MaxAttempts > 0 && failures >= p.MaxAttempts
+}
+
+// Do calls fn until it succeeds, returns an unrecoverable error or the policy is exhausted.
+func Do(p Policy, fn func() error) error {
+	for failures := 1; ; failures++ {
+		err := fn()
+		if err == nil || !errs.IsRecoverable(err) || p.Exhausted(failures) {
+			return err
+		}
+		time.Sleep(p.Backoff(failures))
+	}
+}
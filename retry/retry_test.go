@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{
+		InitialInterval: time.Second,
+		MaxInterval:     time.Second * 10,
+		Multiplier:      2,
+	}
+	assert.Equal(t, time.Second, p.Backoff(1))
+	assert.Equal(t, time.Second*4, p.Backoff(3))
+	assert.Equal(t, time.Second*10, p.Backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(2)
+		assert.True(t, d >= time.Second && d <= time.Second*3)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 3}
+
+	calls := 0
+	err := Do(p, func() error {
+		calls++
+		return errs.Transport("dial", errors.New("connection refused"))
+	})
+	assert.Equal(t, errs.KindTransport, errs.KindOf(err))
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Do(p, func() error {
+		calls++
+		return errs.Key("decode key", errors.New("odd length"))
+	})
+	assert.False(t, errs.IsRecoverable(err))
+	assert.Equal(t, 1, calls)
+
+	//the node rejects a resent request again
+	calls = 0
+	err = Do(p, func() error {
+		calls++
+		return errs.Rejected("send", errors.New("invalid transaction"))
+	})
+	assert.Equal(t, errs.KindRejected, errs.KindOf(err))
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = Do(p, func() error {
+		calls++
+		if calls < 2 {
+			return errs.Transport("send", errors.New("unavailable"))
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,49 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dappley/go-dappley/rpc/pb"
+	"github.com/dappley/iot-security/errs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoContract is returned by ContractCode if the address holds no contract. It is a config
+// error, but unlike a contract that is not a known release, it can not be registered with.
+var ErrNoContract = errors.New("no contract is deployed")
+
+// ContractQuery returns a function that reads a key of the storage of the contract at contractAddr.
+// A missing key reads as an empty value.
+func ContractQuery(conn *grpc.ClientConn, contractAddr string) func(ctx context.Context, key string) (string, error) {
+	return func(ctx context.Context, key string) (string, error) {
+		resp, err := rpcpb.NewRpcServiceClient(conn).RpcContractQuery(ctx, &rpcpb.ContractQueryRequest{
+			ContractAddr: contractAddr,
+			Key:          key,
+		})
+		if status.Code(err) == codes.NotFound {
+			return "", nil
+		}
+		if err != nil {
+			return "", errs.RPC("query contract "+key, err)
+		}
+		return resp.ResultValue, nil
+	}
+}
+
+// ContractCode returns the source of the contract deployed at contractAddr, which the node keeps in the contract's UTXO.
+func ContractCode(ctx context.Context, conn *grpc.ClientConn, contractAddr string) (string, error) {
+	resp, err := rpcpb.NewRpcServiceClient(conn).RpcGetUTXO(ctx, &rpcpb.GetUTXORequest{Address: contractAddr})
+	if err != nil {
+		return "", errs.RPC("get contract utxo", err)
+	}
+	for _, utxo := range resp.Utxos {
+		if utxo.Contract != "" {
+			return utxo.Contract, nil
+		}
+	}
+	return "", errs.Config("get contract code", fmt.Errorf("%w at %s", ErrNoContract, contractAddr))
+}
@@ -0,0 +1,85 @@
+// Package rpcclient dials the gRPC endpoint of a dappley node.
+//
+// Connections use TLS by default. A CA bundle is required to verify the node,
+// and a client certificate and key can be added for mutual TLS. Plaintext
+// connections are only made when they are explicitly allowed with Insecure.
+package rpcclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dappley/iot-security/errs"
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type TLSConfig struct {
+	CaFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	// Insecure allows a plaintext connection to the node
+	Insecure bool
+}
+
+// Dial connects to the node at target, e.g. "localhost:50051".
+func Dial(target string, tlsConfig TLSConfig) (*grpc.ClientConn, error) {
+	opt, err := tlsConfig.DialOption()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(target, opt)
+	if err != nil {
+		return nil, errs.Config("connect to RPC server "+target, err)
+	}
+	return conn, nil
+}
+
+func (c TLSConfig) DialOption() (grpc.DialOption, error) {
+	if c.Insecure {
+		logger.Warn("TLS is disabled. The connection to the node is not encrypted!")
+		return grpc.WithInsecure(), nil
+	}
+	creds, err := c.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	return grpc.WithTransportCredentials(creds), nil
+}
+
+func (c TLSConfig) Credentials() (credentials.TransportCredentials, error) {
+	if c.CaFile == "" {
+		return nil, errs.Config("tls", errors.New("no CA bundle is configured (tls.caFile). Set tls.insecure to connect without TLS"))
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errs.Config("tls", errors.New("tls.certFile and tls.keyFile must be set together"))
+	}
+
+	caPem, err := ioutil.ReadFile(c.CaFile)
+	if err != nil {
+		return nil, errs.Config("tls", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPem) {
+		return nil, errs.Config("tls", fmt.Errorf("no certificate found in %s", c.CaFile))
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    pool,
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errs.Config("tls", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
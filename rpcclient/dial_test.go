@@ -0,0 +1,178 @@
+package rpcclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dappley/iot-security/errs"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type testPKI struct {
+	dir        string
+	caFile     string
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+	caPool     *x509.CertPool
+}
+
+func TestDial_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+	addr, stop := startTLSServer(t, pki)
+	defer stop()
+
+	conn, err := Dial(addr, TLSConfig{
+		CaFile:     pki.caFile,
+		CertFile:   pki.clientCert,
+		KeyFile:    pki.clientKey,
+		ServerName: "node.dappley.test",
+	})
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, checkHealth(conn))
+}
+
+func TestDial_RejectsMissingClientCert(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+	addr, stop := startTLSServer(t, pki)
+	defer stop()
+
+	conn, err := Dial(addr, TLSConfig{CaFile: pki.caFile, ServerName: "node.dappley.test"})
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.NotNil(t, checkHealth(conn))
+}
+
+func TestDial_RejectsWrongServerName(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+	addr, stop := startTLSServer(t, pki)
+	defer stop()
+
+	conn, err := Dial(addr, TLSConfig{
+		CaFile:     pki.caFile,
+		CertFile:   pki.clientCert,
+		KeyFile:    pki.clientKey,
+		ServerName: "other.dappley.test",
+	})
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.NotNil(t, checkHealth(conn))
+}
+
+func TestDial_RefusesInsecureByDefault(t *testing.T) {
+	_, err := Dial("localhost:50051", TLSConfig{})
+	assert.Equal(t, errs.KindConfig, errs.KindOf(err))
+
+	_, err = Dial("localhost:50051", TLSConfig{CaFile: "ca.pem", CertFile: "client.pem"})
+	assert.Equal(t, errs.KindConfig, errs.KindOf(err))
+
+	conn, err := Dial("localhost:50051", TLSConfig{Insecure: true})
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func checkHealth(conn *grpc.ClientConn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	_, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	return err
+}
+
+//startTLSServer starts a local gRPC stand-in for a node that requires client certificates
+func startTLSServer(t *testing.T, pki *testPKI) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert},
+		ClientCAs:    pki.caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	server := grpc.NewServer(grpc.Creds(creds))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	return listener.Addr().String(), server.Stop
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir, err := ioutil.TempDir("", "rpcclient")
+	assert.Nil(t, err)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "iot-security test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.Nil(t, err)
+	caCert, err := x509.ParseCertificate(caDer)
+	assert.Nil(t, err)
+
+	pki := &testPKI{
+		dir:        dir,
+		caFile:     filepath.Join(dir, "ca.pem"),
+		clientCert: filepath.Join(dir, "client.pem"),
+		clientKey:  filepath.Join(dir, "client.key"),
+		caPool:     x509.NewCertPool(),
+	}
+	pki.caPool.AddCert(caCert)
+	writePem(t, pki.caFile, "CERTIFICATE", caDer)
+
+	serverDer, serverKey := issueCert(t, caCert, caKey, 2, x509.ExtKeyUsageServerAuth)
+	pki.serverCert = tls.Certificate{Certificate: [][]byte{serverDer}, PrivateKey: serverKey}
+
+	clientDer, clientKey := issueCert(t, caCert, caKey, 3, x509.ExtKeyUsageClientAuth)
+	writePem(t, pki.clientCert, "CERTIFICATE", clientDer)
+	keyDer, err := x509.MarshalECPrivateKey(clientKey)
+	assert.Nil(t, err)
+	writePem(t, pki.clientKey, "EC PRIVATE KEY", keyDer)
+	return pki
+}
+
+func issueCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "node.dappley.test"},
+		DNSNames:     []string{"node.dappley.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	assert.Nil(t, err)
+	return der, key
+}
+
+func writePem(t *testing.T, path string, blockType string, der []byte) {
+	raw := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	assert.Nil(t, ioutil.WriteFile(path, raw, 0600))
+}
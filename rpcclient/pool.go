@@ -0,0 +1,181 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dappley/go-dappley/rpc/pb"
+	"github.com/dappley/iot-security/errs"
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+const (
+	DefaultHealthCheckInterval = time.Second * 10
+	healthCheckTimeout         = time.Second * 3
+)
+
+// HealthCheck reports whether the node behind conn can serve requests.
+type HealthCheck func(ctx context.Context, conn *grpc.ClientConn) error
+
+// BlockchainInfoCheck treats a node as healthy if it answers RpcGetBlockchainInfo.
+func BlockchainInfoCheck(ctx context.Context, conn *grpc.ClientConn) error {
+	_, err := rpcpb.NewRpcServiceClient(conn).RpcGetBlockchainInfo(ctx, &rpcpb.GetBlockchainInfoRequest{})
+	return err
+}
+
+// Targets returns the configured endpoints, or the local node on port if there are none.
+func Targets(endpoints []string, port int) []string {
+	if len(endpoints) > 0 {
+		return endpoints
+	}
+	return []string{fmt.Sprint(":", port)}
+}
+
+// Pool keeps a connection to every configured endpoint and serves the first healthy one.
+// Endpoints are listed by priority, so the pool fails back to the primary once it recovers.
+type Pool struct {
+	endpoints []string
+	conns     []*grpc.ClientConn
+	healthy   []bool
+	current   int
+	check     HealthCheck
+	mu        sync.RWMutex
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// NewPool dials every endpoint, checks them once and then keeps checking them every interval.
+func NewPool(endpoints []string, tlsConfig TLSConfig, check HealthCheck, interval time.Duration) (*Pool, error) {
+	if len(endpoints) == 0 {
+		return nil, errs.Config("rpc endpoints", errors.New("no endpoint is configured"))
+	}
+	p := &Pool{
+		endpoints: endpoints,
+		healthy:   make([]bool, len(endpoints)),
+		check:     check,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, endpoint := range endpoints {
+		conn, err := Dial(endpoint, tlsConfig)
+		if err != nil {
+			p.closeConns()
+			return nil, err
+		}
+		p.conns = append(p.conns, conn)
+	}
+
+	p.checkAll()
+	go p.run(interval)
+	return p, nil
+}
+
+// Conn returns the connection to the current endpoint.
+func (p *Pool) Conn() *grpc.ClientConn {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.conns[p.current]
+}
+
+func (p *Pool) Endpoint() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.endpoints[p.current]
+}
+
+// ReportFailure marks the current endpoint unhealthy after a failed call and fails over
+// to the next endpoint instead of waiting for the next health check.
+func (p *Pool) ReportFailure() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.endpoints) == 1 {
+		return
+	}
+	p.healthy[p.current] = false
+	for i := 1; i < len(p.endpoints); i++ {
+		next := (p.current + i) % len(p.endpoints)
+		if p.healthy[next] {
+			p.switchTo(next)
+			return
+		}
+	}
+	//nothing is known to be healthy, so try the endpoints in turn
+	p.switchTo((p.current + 1) % len(p.endpoints))
+}
+
+func (p *Pool) Close() {
+	close(p.quit)
+	<-p.done
+	p.closeConns()
+}
+
+func (p *Pool) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.checkAll()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *Pool) checkAll() {
+	healthy := make([]bool, len(p.conns))
+	var wg sync.WaitGroup
+	for i, conn := range p.conns {
+		wg.Add(1)
+		go func(i int, conn *grpc.ClientConn) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+			defer cancel()
+			err := p.check(ctx, conn)
+			if err != nil {
+				logger.WithFields(logger.Fields{
+					"endpoint": p.endpoints[i],
+				}).Debug("Node health check failed. Error:", err)
+			}
+			healthy[i] = err == nil
+		}(i, conn)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.healthy = healthy
+	for i := range healthy {
+		if healthy[i] {
+			p.switchTo(i)
+			return
+		}
+	}
+	logger.WithFields(logger.Fields{
+		"endpoints": p.endpoints,
+	}).Warn("No node endpoint is healthy")
+}
+
+//switchTo must be called with the lock held
+func (p *Pool) switchTo(i int) {
+	if i == p.current {
+		return
+	}
+	logger.WithFields(logger.Fields{
+		"from": p.endpoints[p.current],
+		"to":   p.endpoints[i],
+	}).Warn("Switching node endpoint")
+	p.current = i
+}
+
+func (p *Pool) closeConns() {
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+}
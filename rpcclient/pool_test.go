@@ -0,0 +1,67 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func healthServiceCheck(ctx context.Context, conn *grpc.ClientConn) error {
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return errors.New(resp.Status.String())
+	}
+	return nil
+}
+
+func startNode(t *testing.T) (string, *health.Server, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	return listener.Addr().String(), healthServer, server.Stop
+}
+
+func TestPool_Failover(t *testing.T) {
+	primary, primaryHealth, stopPrimary := startNode(t)
+	defer stopPrimary()
+	backup, _, stopBackup := startNode(t)
+	defer stopBackup()
+
+	pool, err := NewPool([]string{primary, backup}, TLSConfig{Insecure: true}, healthServiceCheck, time.Hour)
+	assert.Nil(t, err)
+	defer pool.Close()
+	assert.Equal(t, primary, pool.Endpoint())
+
+	//the health check moves to the backup when the primary goes down
+	primaryHealth.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	pool.checkAll()
+	assert.Equal(t, backup, pool.Endpoint())
+	assert.Nil(t, healthServiceCheck(context.Background(), pool.Conn()))
+
+	//and back to the primary once it recovers
+	primaryHealth.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	pool.checkAll()
+	assert.Equal(t, primary, pool.Endpoint())
+
+	//a failed call fails over without waiting for the next check
+	pool.ReportFailure()
+	assert.Equal(t, backup, pool.Endpoint())
+}
+
+func TestTargets(t *testing.T) {
+	assert.Equal(t, []string{":50051"}, Targets(nil, 50051))
+	assert.Equal(t, []string{"gw:50051", "10.0.0.2:50051"}, Targets([]string{"gw:50051", "10.0.0.2:50051"}, 50052))
+}
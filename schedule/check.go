@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// storage keys of a node's registration, see iot_security.js
+const (
+	keyPrevInfo = "prevInfo"
+	keyCurrInfo = "currInfo"
+)
+
+// Check checks the registration of addr the way the contract's check does when the node's
+// batch is verified: the node must have registered for the current target cycle, with the
+// same data as in its previous registration. It returns "" if the node passes, or the reason it fails.
+func Check(ctx context.Context, query QueryFunc, addr string) (string, error) {
+	value, err := query(ctx, addr)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "the node has never registered", nil
+	}
+	var info map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &info); err != nil {
+		return "", fmt.Errorf("invalid registration: %v", err)
+	}
+	if fmt.Sprint(info[keyPrevInfo]) != fmt.Sprint(info[keyCurrInfo]) {
+		return "the registered data has changed", nil
+	}
+
+	registered, err := registeredHeight(value)
+	if err != nil {
+		return "", err
+	}
+	value, err = query(ctx, keyTargetStartingBlkHeight)
+	if err != nil {
+		return "", err
+	}
+	start, err := parseHeight(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q", keyTargetStartingBlkHeight, value)
+	}
+	if registered != start {
+		return fmt.Sprintf("the registration is out of date: it is for block %d, the target cycle starts at block %d", registered, start), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,36 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	values := map[string]string{
+		"targetStartingBlkHeight": "5",
+		node1:                     `{"prevInfo":"a","currInfo":"a","blkHeight":"5"}`,
+	}
+	reason, err := Check(context.Background(), storage(values), node1)
+	assert.Nil(t, err)
+	assert.Equal(t, "", reason)
+
+	values[node1] = `{"prevInfo":"a","currInfo":"b","blkHeight":"5"}`
+	reason, err = Check(context.Background(), storage(values), node1)
+	assert.Nil(t, err)
+	assert.Equal(t, "the registered data has changed", reason)
+
+	values[node1] = `{"prevInfo":"a","currInfo":"a","blkHeight":"2"}`
+	reason, err = Check(context.Background(), storage(values), node1)
+	assert.Nil(t, err)
+	assert.Equal(t, "the registration is out of date: it is for block 2, the target cycle starts at block 5", reason)
+
+	reason, err = Check(context.Background(), storage(values), "dbaifMKTn5CLG1MJCcJAvFC1SfaK9RyoVY")
+	assert.Nil(t, err)
+	assert.Equal(t, "the node has never registered", reason)
+
+	values[node1] = "not json"
+	_, err = Check(context.Background(), storage(values), node1)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,140 @@
+// Package schedule reads the contract's current target batch to decide when a
+// registration can actually be accepted.
+//
+// The contract only accepts a registration whose BlkHeight equals the starting block
+// height of its current target batch, and it checks batch i at block start+i+1.
+// A registration is therefore only worth its transaction fee once per target cycle,
+// between the start of the cycle and the check of the node's batch.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// storage keys of the contract, see iot_security.js
+const (
+	keyTargetStartingBlkHeight = "targetStartingBlkHeight"
+	keyTargetAddresses         = "targetAddresses"
+	keyBlkHeight               = "blkHeight"
+)
+
+// QueryFunc reads a key of the contract storage. It returns an empty value for a missing key.
+type QueryFunc func(ctx context.Context, key string) (string, error)
+
+// Window is the registration window of a node in the contract's current target cycle.
+type Window struct {
+	// Start is the starting block height of the target batches, the BlkHeight a registration must carry.
+	// It is 0 until the contract has been set up
+	Start uint64
+	// Batch is the index of the node's target batch, or -1 if the node is not a target
+	Batch int
+	// Registered is true if the node has already registered for Start
+	Registered bool
+	// RegisteredHeight is the BlkHeight of the node's last registration stored by the contract, 0 if none
+	RegisteredHeight uint64
+}
+
+// Read reads the window of addr from the contract storage.
+func Read(ctx context.Context, query QueryFunc, addr string) (Window, error) {
+	window := Window{Batch: -1}
+
+	value, err := query(ctx, keyTargetStartingBlkHeight)
+	if err != nil {
+		return window, err
+	}
+	if window.Start, err = parseHeight(value); err != nil {
+		return window, fmt.Errorf("invalid %s %q", keyTargetStartingBlkHeight, value)
+	}
+	if window.Start == 0 {
+		return window, nil
+	}
+
+	value, err = query(ctx, keyTargetAddresses)
+	if err != nil {
+		return window, err
+	}
+	if window.Batch, err = findBatch(value, addr); err != nil {
+		return window, err
+	}
+
+	value, err = query(ctx, addr)
+	if err != nil {
+		return window, err
+	}
+	if window.RegisteredHeight, err = registeredHeight(value); err != nil {
+		return window, err
+	}
+	window.Registered = window.RegisteredHeight == window.Start
+	return window, nil
+}
+
+// Deadline is the block at which the contract checks the node's batch.
+func (w Window) Deadline() uint64 {
+	return w.Start + uint64(w.Batch) + 1
+}
+
+// Accepts reports whether a registration for Start that is sent at blkHeight can be mined
+// before the node's batch is checked.
+func (w Window) Accepts(blkHeight uint64) bool {
+	return w.Start > 0 && w.Batch >= 0 && blkHeight >= w.Start && blkHeight < w.Deadline()
+}
+
+// Reason explains why the window does not accept a registration at blkHeight.
+func (w Window) Reason(blkHeight uint64) string {
+	switch {
+	case w.Start == 0:
+		return "the contract has not set its target batches yet"
+	case w.Batch < 0:
+		return "the node is not in the contract's target batches"
+	case blkHeight < w.Start:
+		return "the node is behind the contract's target batches"
+	case blkHeight >= w.Deadline():
+		return "the node's target batch has already been checked"
+	}
+	return ""
+}
+
+// findBatch finds addr in the batches, stored as {"0":"addr1,addr2","1":"addr3"}
+func findBatch(value string, addr string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+	batches := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &batches); err != nil {
+		return -1, fmt.Errorf("invalid %s: %v", keyTargetAddresses, err)
+	}
+	for index, batch := range batches {
+		for _, target := range strings.Split(batch, ",") {
+			if target == addr {
+				return strconv.Atoi(index)
+			}
+		}
+	}
+	return -1, nil
+}
+
+// registeredHeight returns the block height of the node's last registration, stored as {"blkHeight":"12",...}
+func registeredHeight(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	var info map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &info); err != nil {
+		return 0, fmt.Errorf("invalid registration: %v", err)
+	}
+	if info[keyBlkHeight] == nil {
+		return 0, nil
+	}
+	return parseHeight(fmt.Sprint(info[keyBlkHeight]))
+}
+
+func parseHeight(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
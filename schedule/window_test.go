@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const node1 = "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
+
+func storage(values map[string]string) QueryFunc {
+	return func(ctx context.Context, key string) (string, error) {
+		return values[key], nil
+	}
+}
+
+func TestRead(t *testing.T) {
+	values := map[string]string{
+		"targetStartingBlkHeight": "5",
+		"targetAddresses":         `{"0":"dRE5XUM2demeG8unwsWgs1WRGSUGdgWaDo","1":"dEhFf5mWTSe67mbemZdK3WiJh8FcCayJqm,dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP","2":"dHvB2CF9PUtih7VM1VUZmf3g25ZGfNym5A"}`,
+		node1:                     `{"prevInfo":"a","currInfo":"a","blkHeight":"2"}`,
+	}
+	window, err := Read(context.Background(), storage(values), node1)
+	assert.Nil(t, err)
+	assert.Equal(t, Window{Start: 5, Batch: 1, RegisteredHeight: 2}, window)
+	assert.Equal(t, uint64(7), window.Deadline())
+
+	//not accepted before the cycle starts or once the batch is checked
+	assert.False(t, window.Accepts(4))
+	assert.True(t, window.Accepts(5))
+	assert.True(t, window.Accepts(6))
+	assert.False(t, window.Accepts(7))
+	assert.Equal(t, "the node's target batch has already been checked", window.Reason(7))
+
+	values[node1] = `{"prevInfo":"a","currInfo":"a","blkHeight":"5"}`
+	window, err = Read(context.Background(), storage(values), node1)
+	assert.Nil(t, err)
+	assert.True(t, window.Registered)
+	assert.Equal(t, uint64(5), window.RegisteredHeight)
+
+	window, err = Read(context.Background(), storage(values), "dbaifMKTn5CLG1MJCcJAvFC1SfaK9RyoVY")
+	assert.Nil(t, err)
+	assert.Equal(t, -1, window.Batch)
+	assert.False(t, window.Accepts(5))
+}
+
+func TestRead_BeforeSetup(t *testing.T) {
+	window, err := Read(context.Background(), storage(map[string]string{}), node1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), window.Start)
+	assert.False(t, window.Accepts(1))
+	assert.Equal(t, "the contract has not set its target batches yet", window.Reason(1))
+}
+
+func TestRead_Errors(t *testing.T) {
+	_, err := Read(context.Background(), storage(map[string]string{"targetStartingBlkHeight": "x"}), node1)
+	assert.NotNil(t, err)
+
+	_, err = Read(context.Background(), storage(map[string]string{
+		"targetStartingBlkHeight": "5",
+		"targetAddresses":         "not json",
+	}), node1)
+	assert.NotNil(t, err)
+
+	failed := errors.New("unavailable")
+	_, err = Read(context.Background(), func(ctx context.Context, key string) (string, error) {
+		return "", failed
+	}, node1)
+	assert.Equal(t, failed, err)
+}
@@ -0,0 +1,193 @@
+//go:build pkcs11
+// +build pkcs11
+
+package signer
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+const PinEnv = "IOTSEC_PKCS11_PIN"
+
+// DER encoding of the secp256k1 curve OID 1.3.132.0.10
+var secp256k1Params = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// PKCS11Signer signs with a secp256k1 key that never leaves the token.
+type PKCS11Signer struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	pubKey  []byte
+	address string
+	mu      sync.Mutex
+}
+
+func NewPKCS11Signer(config Config) (Signer, error) {
+	if config.Module == "" || config.TokenLabel == "" || config.KeyLabel == "" {
+		return nil, errors.New("pkcs11 signer needs module, tokenLabel and keyLabel")
+	}
+	pin, err := readPin(config.PinFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := pkcs11.New(config.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("can not load PKCS#11 module %s", config.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+	s := &PKCS11Signer{ctx: ctx}
+	if err := s.open(config, pin); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PKCS11Signer) open(config Config, pin string) error {
+	slot, err := findSlot(s.ctx, config.TokenLabel)
+	if err != nil {
+		return err
+	}
+	s.session, err = s.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return err
+	}
+	if err := s.ctx.Login(s.session, pkcs11.CKU_USER, pin); err != nil {
+		return err
+	}
+
+	s.key, err = s.findKey(pkcs11.CKO_PRIVATE_KEY, config.KeyLabel)
+	if err != nil {
+		return err
+	}
+	pubHandle, err := s.findKey(pkcs11.CKO_PUBLIC_KEY, config.KeyLabel)
+	if err != nil {
+		return err
+	}
+	s.pubKey, err = s.readPublicKey(pubHandle)
+	if err != nil {
+		return err
+	}
+	s.address, err = AddressOf(s.pubKey)
+	return err
+}
+
+func (s *PKCS11Signer) Sign(digest []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+	if err := s.ctx.SignInit(s.session, mechanism, s.key); err != nil {
+		return nil, err
+	}
+	rs, err := s.ctx.Sign(s.session, digest)
+	if err != nil {
+		return nil, err
+	}
+	return toRecoverable(digest, rs, s.pubKey)
+}
+
+func (s *PKCS11Signer) PublicKey() []byte { return s.pubKey }
+
+func (s *PKCS11Signer) Address() string { return s.address }
+
+func (s *PKCS11Signer) Close() {
+	if s.session != 0 {
+		s.ctx.Logout(s.session)
+		s.ctx.CloseSession(s.session)
+	}
+	s.ctx.Finalize()
+	s.ctx.Destroy()
+}
+
+func findSlot(ctx *pkcs11.Ctx, tokenLabel string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err == nil && info.Label == tokenLabel {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("PKCS#11 token %q is not found", tokenLabel)
+}
+
+func (s *PKCS11Signer) findKey(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, err
+	}
+	handles, _, err := s.ctx.FindObjects(s.session, 2)
+	s.ctx.FindObjectsFinal(s.session)
+	if err != nil {
+		return 0, err
+	}
+	if len(handles) != 1 {
+		return 0, fmt.Errorf("expected one EC key labelled %q, found %d", label, len(handles))
+	}
+	return handles[0], nil
+}
+
+func (s *PKCS11Signer) readPublicKey(handle pkcs11.ObjectHandle) ([]byte, error) {
+	attrs, err := s.ctx.GetAttributeValue(s.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var params, point []byte
+	for _, attr := range attrs {
+		switch attr.Type {
+		case pkcs11.CKA_EC_PARAMS:
+			params = attr.Value
+		case pkcs11.CKA_EC_POINT:
+			point = attr.Value
+		}
+	}
+	if !bytes.Equal(params, secp256k1Params) {
+		return nil, errors.New("the key is not on the secp256k1 curve")
+	}
+
+	//CKA_EC_POINT is a DER OCTET STRING wrapping the uncompressed point
+	var raw []byte
+	if _, err := asn1.Unmarshal(point, &raw); err != nil {
+		raw = point
+	}
+	if len(raw) != 65 || raw[0] != 0x04 {
+		return nil, errors.New("unsupported EC point encoding")
+	}
+	return raw[1:], nil
+}
+
+func readPin(pinFile string) (string, error) {
+	if pin, ok := os.LookupEnv(PinEnv); ok {
+		return pin, nil
+	}
+	if pinFile == "" {
+		return "", errors.New("no PKCS#11 PIN: set " + PinEnv + " or pinFile")
+	}
+	raw, err := ioutil.ReadFile(pinFile)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(raw, "\r\n")), nil
+}
@@ -0,0 +1,11 @@
+//go:build !pkcs11
+// +build !pkcs11
+
+package signer
+
+import "errors"
+
+// NewPKCS11Signer is only available in binaries built with the pkcs11 tag, which requires cgo.
+func NewPKCS11Signer(config Config) (Signer, error) {
+	return nil, errors.New("this binary is built without PKCS#11 support. Rebuild it with -tags pkcs11")
+}
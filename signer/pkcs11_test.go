@@ -0,0 +1,98 @@
+//go:build pkcs11
+// +build pkcs11
+
+package signer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dappley/go-dappley/crypto/keystore/secp256k1"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTokenLabel = "iotsec"
+	testKeyLabel   = "node"
+	testPin        = "1234"
+)
+
+// TestPKCS11Signer runs against SoftHSM, e.g.
+// SOFTHSM2_MODULE=/usr/lib/softhsm/libsofthsm2.so go test -tags pkcs11 ./signer
+func TestPKCS11Signer(t *testing.T) {
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if module == "" {
+		t.Skip("SOFTHSM2_MODULE is not set")
+	}
+	dir, err := ioutil.TempDir("", "softhsm")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	initToken(t, dir, module)
+	generateKey(t, module)
+
+	os.Setenv(PinEnv, testPin)
+	defer os.Unsetenv(PinEnv)
+	s, err := NewPKCS11Signer(Config{Type: TypePKCS11, Module: module, TokenLabel: testTokenLabel, KeyLabel: testKeyLabel})
+	assert.Nil(t, err)
+	defer s.(*PKCS11Signer).Close()
+
+	expected, err := AddressOf(s.PublicKey())
+	assert.Nil(t, err)
+	assert.Equal(t, expected, s.Address())
+
+	digest := sha256.Sum256([]byte("measurements"))
+	sig, err := s.Sign(digest[:])
+	assert.Nil(t, err)
+	assert.Equal(t, 65, len(sig))
+	recovered, err := secp256k1.RecoverPubkey(digest[:], sig)
+	assert.Nil(t, err)
+	assert.Equal(t, s.PublicKey(), recovered[1:])
+}
+
+func initToken(t *testing.T, dir string, module string) {
+	tokens := filepath.Join(dir, "tokens")
+	assert.Nil(t, os.Mkdir(tokens, 0700))
+	conf := filepath.Join(dir, "softhsm2.conf")
+	assert.Nil(t, ioutil.WriteFile(conf, []byte(fmt.Sprintf("directories.tokendir = %s\n", tokens)), 0600))
+	os.Setenv("SOFTHSM2_CONF", conf)
+
+	out, err := exec.Command("softhsm2-util", "--init-token", "--free", "--label", testTokenLabel,
+		"--so-pin", testPin, "--pin", testPin, "--module", module).CombinedOutput()
+	assert.Nil(t, err, string(out))
+}
+
+func generateKey(t *testing.T, module string) {
+	ctx := pkcs11.New(module)
+	assert.Nil(t, ctx.Initialize())
+	defer ctx.Destroy()
+	defer ctx.Finalize()
+
+	slot, err := findSlot(ctx, testTokenLabel)
+	assert.Nil(t, err)
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	assert.Nil(t, err)
+	defer ctx.CloseSession(session)
+	assert.Nil(t, ctx.Login(session, pkcs11.CKU_USER, testPin))
+
+	_, _, err = ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, secp256k1Params),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		})
+	assert.Nil(t, err)
+}
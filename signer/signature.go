@@ -0,0 +1,38 @@
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/dappley/go-dappley/crypto/keystore/secp256k1"
+)
+
+// toRecoverable turns a plain 64-byte [R || S] ECDSA signature, as returned by
+// hardware tokens, into the 65-byte [R || S || V] form of secp256k1.Sign.
+// S is normalized to the lower half of the curve order and V is found by
+// recovering the public key from the signature.
+func toRecoverable(digest []byte, rs []byte, pubKey []byte) ([]byte, error) {
+	if len(rs) != 64 {
+		return nil, errors.New("invalid ECDSA signature length")
+	}
+	n := secp256k1.S256().Params().N
+	s := new(big.Int).SetBytes(rs[32:])
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+
+	sig := make([]byte, 65)
+	copy(sig, rs[:32])
+	sBytes := s.Bytes()
+	copy(sig[64-len(sBytes):64], sBytes)
+
+	for v := byte(0); v < 2; v++ {
+		sig[64] = v
+		recovered, err := secp256k1.RecoverPubkey(digest, sig)
+		if err == nil && len(recovered) == 65 && bytes.Equal(recovered[1:], pubKey) {
+			return sig, nil
+		}
+	}
+	return nil, errors.New("the signature does not match the public key")
+}
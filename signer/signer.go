@@ -0,0 +1,62 @@
+// Package signer abstracts the secp256k1 key that signs registrations and setup calls.
+//
+// A Signer produces the same 65-byte [R || S || V] signatures as secp256k1.Sign in
+// go-dappley, so the contract verifies them the same way whether the key lives in
+// a software keystore or in a secure element reached through PKCS#11.
+package signer
+
+import (
+	"fmt"
+
+	"github.com/dappley/go-dappley/core"
+)
+
+const (
+	TypeSoftware = "software"
+	TypePKCS11   = "pkcs11"
+)
+
+type Signer interface {
+	// Sign signs a 32-byte digest
+	Sign(digest []byte) ([]byte, error)
+	// PublicKey returns the 64-byte uncompressed public key without its 0x04 prefix
+	PublicKey() []byte
+	// Address returns the dappley address of the public key
+	Address() string
+}
+
+// Config selects the signer. The software signer is used if Type is empty.
+type Config struct {
+	Type string
+	// PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Module     string
+	TokenLabel string
+	KeyLabel   string
+	// PinFile holds the user PIN of the token. IOTSEC_PKCS11_PIN takes precedence
+	PinFile string
+}
+
+// AddressOf returns the dappley user address of a 64-byte public key
+func AddressOf(pubKey []byte) (string, error) {
+	pkh, err := core.NewUserPubKeyHash(pubKey)
+	if err != nil {
+		return "", err
+	}
+	return pkh.GenerateAddress().String(), nil
+}
+
+// New creates the signer selected by config. loadKey supplies the raw private key of the software signer.
+func New(config Config, loadKey func() ([]byte, error)) (Signer, error) {
+	switch config.Type {
+	case "", TypeSoftware:
+		privKey, err := loadKey()
+		if err != nil {
+			return nil, err
+		}
+		return NewSoftwareSigner(privKey)
+	case TypePKCS11:
+		return NewPKCS11Signer(config)
+	default:
+		return nil, fmt.Errorf("unknown signer type %q", config.Type)
+	}
+}
@@ -0,0 +1,98 @@
+package signer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/dappley/go-dappley/crypto/keystore/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+// key pair of node1 in conf/node1.conf
+const (
+	node1PrivKey = "f22bac4a73a9881d523075d9bb749ca537c7fa451366d935bcb65509968ac3e4"
+	node1PubKey  = "fd2681827b0e3be73d21e3238b155fce269d7c356f2b85a74a6b0bf6514cbd345dc848a7dad99d7d61dbd8a5e08ac0b21a52b8fc575e29af6f9e089b1bbb7c82"
+	node1Addr    = "dGGG6kfCL1MtGgaHXAJJXDJ4KxLSD2EdEP"
+)
+
+func TestSoftwareSigner(t *testing.T) {
+	privKey, _ := hex.DecodeString(node1PrivKey)
+	s, err := NewSoftwareSigner(privKey)
+	assert.Nil(t, err)
+	assert.Equal(t, node1PubKey, hex.EncodeToString(s.PublicKey()))
+	assert.Equal(t, node1Addr, s.Address())
+
+	digest := sha256.Sum256([]byte("measurements"))
+	sig, err := s.Sign(digest[:])
+	assert.Nil(t, err)
+	recovered, err := secp256k1.RecoverPubkey(digest[:], sig)
+	assert.Nil(t, err)
+	assert.Equal(t, s.PublicKey(), recovered[1:])
+
+	_, err = NewSoftwareSigner(privKey[:31])
+	assert.NotNil(t, err)
+}
+
+func TestGenerateKey(t *testing.T) {
+	privKey, err := GenerateKey()
+	assert.Nil(t, err)
+	assert.Len(t, privKey, 32)
+	s, err := NewSoftwareSigner(privKey)
+	assert.Nil(t, err)
+
+	digest := sha256.Sum256([]byte("rotation"))
+	sig, err := s.Sign(digest[:])
+	assert.Nil(t, err)
+	recovered, err := secp256k1.RecoverPubkey(digest[:], sig)
+	assert.Nil(t, err)
+	assert.Equal(t, s.PublicKey(), recovered[1:])
+
+	other, err := GenerateKey()
+	assert.Nil(t, err)
+	assert.NotEqual(t, privKey, other)
+}
+
+func TestToRecoverable(t *testing.T) {
+	privKey, _ := hex.DecodeString(node1PrivKey)
+	s, err := NewSoftwareSigner(privKey)
+	assert.Nil(t, err)
+	digest := sha256.Sum256([]byte("measurements"))
+	sig, err := s.Sign(digest[:])
+	assert.Nil(t, err)
+
+	//a token may return either S or N-S, both are valid ECDSA signatures
+	rs := append([]byte{}, sig[:64]...)
+	assert.Equal(t, sig, mustRecoverable(t, digest[:], rs, s.PublicKey()))
+
+	n := secp256k1.S256().Params().N
+	highS := new(big.Int).Sub(n, new(big.Int).SetBytes(rs[32:])).Bytes()
+	copy(rs[64-len(highS):], highS)
+	assert.Equal(t, sig, mustRecoverable(t, digest[:], rs, s.PublicKey()))
+
+	other := sha256.Sum256([]byte("other"))
+	_, err = toRecoverable(other[:], sig[:64], s.PublicKey())
+	assert.NotNil(t, err)
+}
+
+func TestNew(t *testing.T) {
+	privKey, _ := hex.DecodeString(node1PrivKey)
+	loadKey := func() ([]byte, error) { return privKey, nil }
+
+	s, err := New(Config{}, loadKey)
+	assert.Nil(t, err)
+	assert.Equal(t, node1Addr, s.Address())
+
+	_, err = New(Config{Type: TypeSoftware}, func() ([]byte, error) { return nil, errors.New("no key") })
+	assert.NotNil(t, err)
+	_, err = New(Config{Type: "tpm"}, loadKey)
+	assert.NotNil(t, err)
+}
+
+func mustRecoverable(t *testing.T, digest, rs, pubKey []byte) []byte {
+	sig, err := toRecoverable(digest, rs, pubKey)
+	assert.Nil(t, err)
+	return sig
+}
@@ -0,0 +1,53 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+
+	"github.com/dappley/go-dappley/crypto/keystore/secp256k1"
+)
+
+type SoftwareSigner struct {
+	privKey []byte
+	pubKey  []byte
+	address string
+}
+
+func NewSoftwareSigner(privKey []byte) (*SoftwareSigner, error) {
+	if len(privKey) != 32 {
+		return nil, errors.New("a secp256k1 private key is 32 bytes long")
+	}
+	ecdsaKey, err := secp256k1.ToECDSAPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := secp256k1.FromECDSAPublicKey(&ecdsaKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	//dappley public keys do not carry the 0x04 prefix of uncompressed points
+	pubKey = pubKey[1:]
+	address, err := AddressOf(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SoftwareSigner{privKey: privKey, pubKey: pubKey, address: address}, nil
+}
+
+// GenerateKey generates a secp256k1 private key for a SoftwareSigner, e.g. the new key of an admin rotation
+func GenerateKey() ([]byte, error) {
+	ecdsaKey, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return secp256k1.FromECDSAPrivateKey(ecdsaKey)
+}
+
+func (s *SoftwareSigner) Sign(digest []byte) ([]byte, error) {
+	return secp256k1.Sign(digest, s.privKey)
+}
+
+func (s *SoftwareSigner) PublicKey() []byte { return s.pubKey }
+
+func (s *SoftwareSigner) Address() string { return s.address }